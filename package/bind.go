@@ -1,11 +1,11 @@
 package env_manager
 
 import (
-	"os"
+	"errors"
+	"fmt"
 	"reflect"
 	"slices"
 	"strings"
-	"time"
 )
 
 const (
@@ -14,6 +14,12 @@ const (
 	STRUCT_TAG_DELIMITER     = "env_delim"
 	STRUCT_TAG_PREFIX        = "env_prefix"
 	STRUCT_TAG_KEYS          = "env_keys"
+	STRUCT_TAG_VALIDATE      = "env_validate"
+	STRUCT_TAG_LAYOUT        = "env_layout"
+	STRUCT_TAG_UNIT          = "env_unit"
+	STRUCT_TAG_MAP_KEY       = "env_map_key"
+	STRUCT_TAG_FORMAT        = "env_format"
+	STRUCT_TAG_KV_SEPARATOR  = "env_kvsep"
 )
 
 const (
@@ -21,26 +27,41 @@ const (
 	STRUCT_KEYWORD_ALL    = "*"
 )
 
-func (e *EnvManager) bindEnvWithPrefix(envStructPtr any, prefix string) error {
+// Keywords of the env_map_key tag, they change how env keys are turned into map keys
+// example: `env_keys:"USER_*" env_map_key:"strip,lower"` binds USER_ADMIN to the map key "admin"
+const (
+	MAP_KEY_STRIP = "strip" // remove the field prefix and the matched prefix of wildcards
+	MAP_KEY_LOWER = "lower" // lowercase the key
+	MAP_KEY_UPPER = "upper" // uppercase the key
+	MAP_KEY_CAMEL = "camel" // convert SNAKE_CASE keys to camelCase
+)
+
+// bindEnvWithPrefix binds every field of the struct and returns all the failures as EnvErrors,
+// path is the field path of the struct used in error messages
+func (e *EnvManager) bindEnvWithPrefix(envStructPtr any, prefix, path string) error {
 	// the varaible provided must be a struct ptr
 	varType := reflect.TypeOf(envStructPtr)
-	if varType.Kind() != reflect.Pointer || varType.Elem().Kind() != reflect.Struct {
+	if varType == nil || varType.Kind() != reflect.Pointer || varType.Elem().Kind() != reflect.Struct {
 		return newInvalidUsageErr("binding varaible", "binding variable must be a pointer to a struct")
 	}
 
 	envStructType := varType.Elem()
-	// loop on each field of struct
+	var errs EnvErrors
+	// loop on each field of struct, collecting the errors instead of stopping at the first one
 	for i := range envStructType.NumField() {
-		if err := e.handleField(envStructPtr, envStructType, i, prefix); err != nil {
-			return err
+		fieldPath := joinFieldPath(path, envStructType.Field(i).Name)
+		if err := e.handleField(envStructPtr, envStructType, i, prefix, fieldPath); err != nil {
+			errs = errs.add(err, fieldPath)
 		}
 	}
+	if len(errs) > 0 {
+		return errs
+	}
 	return nil
 }
 
-func (e *EnvManager) handleField(envStructPtr any, envStructType reflect.Type, i int, prefix string) error {
+func (e *EnvManager) handleField(envStructPtr any, envStructType reflect.Type, i int, prefix, path string) error {
 	field := envStructType.Field(i)
-	fieldType := field.Type
 	envTag := strings.Split(field.Tag.Get(STRUCT_TAG_ENV), ",")
 
 	fieldPrefix := prefix
@@ -58,78 +79,83 @@ func (e *EnvManager) handleField(envStructPtr any, envStructType reflect.Type, i
 
 	envVarName := e.getNameFromTag(envTag, field.Name)
 
-	if fieldType.Kind() == reflect.Map {
-		if mapValue, err := e.castMap(field, fieldPrefix); err != nil {
-			return err
-		} else {
-			e.setField(i, field.Name, envStructPtr, mapValue)
-		}
-		return nil
-	} else if checkType(fieldType, "time.Duration") {
+	// errors of values read from a single key cite where the value was declared
+	envKey := joinPrefix(fieldPrefix, envVarName)
+	key, value, err := e.fieldValue(field, fieldPrefix, envVarName, path)
+	if err != nil {
+		return e.withOrigin(err, envKey)
+	}
+	if err := validateField(field, value); err != nil {
+		return e.withOrigin(err, envKey)
+	}
+	e.setField(i, key, envStructPtr, value)
+	return nil
+}
+
+// fieldValue looks up and casts the value of a struct field, it returns the env key used for logging
+func (e *EnvManager) fieldValue(field reflect.StructField, fieldPrefix, envVarName, path string) (string, reflect.Value, error) {
+	fieldType := field.Type
+	emptyValue := reflect.Value{}
+	opts := e.getCastOptions(field)
+
+	switch {
+	case field.Tag.Get(STRUCT_TAG_FORMAT) != "":
+		// the whole value is decoded in the given format, eg: json into maps, slices and structs
 		key, valStr, err := e.getEnvValue(fieldPrefix, envVarName, getDefaultValue(field))
 		if err != nil {
-			return err
-		}
-		if t, err := time.ParseDuration(valStr); err != nil {
-			return err
-		} else {
-			e.setField(i, key, envStructPtr, reflect.ValueOf(t))
-			return nil
+			if fieldType.Kind() == reflect.Pointer {
+				return field.Name, reflect.Zero(fieldType), nil
+			}
+			return key, emptyValue, err
 		}
-	} else if fieldType.Kind() == reflect.Struct {
+		value, err := e.decodeFormattedValue(field, field.Tag.Get(STRUCT_TAG_FORMAT), key, valStr)
+		return key, value, err
+	case hasCustomCast(fieldType, opts):
+		// built-in types (time.Time, *url.URL...) and types implementing EnvDecoder or
+		// encoding.TextUnmarshaler are decoded from the raw value below, even when they are structs or maps
+	case fieldType.Kind() == reflect.Slice && isStructType(fieldType.Elem(), opts):
+		sliceValue, err := e.bindStructSlice(field, joinPrefix(fieldPrefix, envVarName), path)
+		return field.Name, sliceValue, err
+	case fieldType.Kind() == reflect.Map && isStructType(fieldType.Elem(), opts):
+		mapValue, err := e.bindStructMap(field, joinPrefix(fieldPrefix, envVarName), path)
+		return field.Name, mapValue, err
+	case fieldType.Kind() == reflect.Map && field.Tag.Get(STRUCT_TAG_KEYS) != "":
+		// maps without env_keys are read from a single variable of key/value pairs below
+		mapValue, err := e.castMap(field, fieldPrefix)
+		return field.Name, mapValue, err
+	case fieldType.Kind() == reflect.Struct:
 		structPtr := reflect.New(fieldType)
-		if err := e.bindEnvWithPrefix(structPtr.Interface(), fieldPrefix); err != nil {
-			return err
-		} else {
-			e.setField(i, field.Name, envStructPtr, structPtr.Elem())
-			return nil
+		if err := e.bindEnvWithPrefix(structPtr.Interface(), fieldPrefix, path); err != nil {
+			return field.Name, emptyValue, err
 		}
-	} else if fieldType.Kind() == reflect.Pointer && fieldType.Elem().Kind() == reflect.Struct {
+		return field.Name, structPtr.Elem(), nil
+	case fieldType.Kind() == reflect.Pointer && fieldType.Elem().Kind() == reflect.Struct:
 		structPtr := reflect.New(fieldType.Elem())
-		if err := e.bindEnvWithPrefix(structPtr.Interface(), fieldPrefix); err != nil {
-			return err
-		} else {
-			e.setField(i, field.Name, envStructPtr, structPtr)
-			return nil
+		if err := e.bindEnvWithPrefix(structPtr.Interface(), fieldPrefix, path); err != nil {
+			return field.Name, emptyValue, err
 		}
+		return field.Name, structPtr, nil
 	}
 
 	key, valStr, err := e.getEnvValue(fieldPrefix, envVarName, getDefaultValue(field))
 	if err != nil {
 		if field.Type.Kind() == reflect.Pointer {
 			e.Log(LOW, "Pointer field %s not found in environment variables, setting to nil", field.Name)
-			e.setField(i, field.Name, envStructPtr, reflect.Zero(fieldType))
-			return nil
+			return field.Name, reflect.Zero(fieldType), nil
 		} else {
-			return newKeyNotFoundErr(key)
+			return key, emptyValue, newKeyNotFoundErr(key)
 		}
 	}
 
-	if isPrimitiveKind(fieldType) {
-		if value, err := castStringToPrimitive(valStr, fieldType); err != nil {
-			return newTypeCastErr(valStr, fieldType.Name(), err)
-		} else {
-			e.setField(i, field.Name, envStructPtr, value)
-		}
-	} else if fieldType.Kind() == reflect.Slice && isPrimitiveKind(fieldType.Elem()) {
-		delim := getDelim(field)
-		if value, err := castStringToSlice(valStr, fieldType.Elem(), delim); err != nil {
-			return newTypeCastErr(valStr, fieldType.Name(), err)
-		} else {
-			e.setField(i, field.Name, envStructPtr, value)
-		}
-	} else if fieldType.Kind() == reflect.Pointer {
-		if value, err := castString(valStr, fieldType.Elem(), ""); err != nil {
-			return newTypeCastErr(valStr, fieldType.Name(), err)
-		} else {
-			ptrValue := reflect.New(fieldType.Elem())
-			ptrValue.Elem().Set(value)
-			e.setField(i, field.Name, envStructPtr, ptrValue)
+	// primitives, slices, key/value maps, pointers and decoder types
+	if value, err := castString(valStr, fieldType, opts); err != nil {
+		if errors.Is(err, errUnsupportedType) {
+			return key, emptyValue, newUnSupportedTypeError(field.Name, fieldType.String())
 		}
+		return key, emptyValue, err
 	} else {
-		return newUnSupportedTypeError(field.Name, fieldType.Name())
+		return field.Name, value, nil
 	}
-	return nil
 }
 
 func (e *EnvManager) castMap(field reflect.StructField, fieldPrefix string) (reflect.Value, error) {
@@ -139,43 +165,202 @@ func (e *EnvManager) castMap(field reflect.StructField, fieldPrefix string) (ref
 		return emptyValue, newNoKeysForMapErr(field.Name)
 	}
 
+	opts := e.getCastOptions(field)
+	keyOpts := parseMapKeyOptions(field.Tag.Get(STRUCT_TAG_MAP_KEY))
+	// env keys to look up, mapped to the name used as the map key when stripping
 	keysList := []string{}
-	delim := getDelim(field)
+	names := map[string]string{}
 
-	if strings.HasSuffix(keys, "*") {
-		keyPrefix := strings.TrimSuffix(keys, "*")
-		for key := range e.envMap {
-			if strings.HasPrefix(key, keyPrefix) {
+	if strings.HasSuffix(keys, STRUCT_KEYWORD_ALL) {
+		// wildcard keys are matched on every key of the sources, including the field prefix
+		keyPrefix := joinPrefix(fieldPrefix, strings.TrimSuffix(keys, STRUCT_KEYWORD_ALL))
+		for _, key := range e.source().Keys() {
+			if rest, found := strings.CutPrefix(key, keyPrefix); found {
 				keysList = append(keysList, key)
+				names[key] = strings.TrimLeft(rest, "_")
 			}
 		}
+		slices.Sort(keysList)
 	} else {
-		keysList = strings.Split(keys, delim)
-		if len(keysList) == 0 {
-			return emptyValue, newNoKeysForMapErr(field.Name)
+		for _, key := range strings.Split(keys, opts.delim) {
+			if key == "" {
+				return emptyValue, newNoKeysForMapErr(field.Name)
+			}
+			fullKey := joinPrefix(fieldPrefix, key)
+			keysList = append(keysList, fullKey)
+			names[fullKey] = key
 		}
 	}
 
 	mapValue := reflect.MakeMap(field.Type)
 	for _, key := range keysList {
-		if key == "" {
-			return emptyValue, newNoKeysForMapErr(field.Name)
-		}
-
-		key, val, err := e.getEnvValue(fieldPrefix, key, getDefaultValue(field))
+		key, val, err := e.getEnvValue("", key, getDefaultValue(field))
 		if err != nil {
 			return emptyValue, err
 		}
 
-		if elemValue, err := castString(val, field.Type.Elem(), delim); err != nil {
-			return emptyValue, newTypeCastErr(val, field.Type.Name(), err)
+		mapKey := key
+		if keyOpts.strip {
+			mapKey = names[key]
+		}
+		// castString errors are already cast errors, only the unsupported types are reported here
+		keyValue, err := castString(keyOpts.transform(mapKey), field.Type.Key(), opts.forKeys())
+		if errors.Is(err, errUnsupportedType) {
+			return emptyValue, newUnSupportedTypeError(field.Name, field.Type.String())
+		} else if err != nil {
+			return emptyValue, e.withOrigin(err, key)
+		}
+
+		if elemValue, err := castString(val, field.Type.Elem(), opts); errors.Is(err, errUnsupportedType) {
+			return emptyValue, newUnSupportedTypeError(field.Name, field.Type.String())
+		} else if err != nil {
+			return emptyValue, e.withOrigin(err, key)
 		} else {
-			mapValue.SetMapIndex(reflect.ValueOf(key), elemValue)
+			mapValue.SetMapIndex(keyValue, elemValue)
+		}
+	}
+	return mapValue, nil
+}
+
+// bindStructSlice binds a slice of structs from indexed keys, eg: BACKENDS_0_HOST, BACKENDS_1_HOST...
+// the indexes must start at 0 and be contiguous, the slice is empty when no key matches
+func (e *EnvManager) bindStructSlice(field reflect.StructField, keyPrefix, path string) (reflect.Value, error) {
+	keys := e.source().Keys()
+	sliceValue := reflect.MakeSlice(field.Type, 0, 0)
+	var errs EnvErrors
+	for i := 0; hasKeyWithPrefix(keys, fmt.Sprintf("%s_%d_", keyPrefix, i)); i++ {
+		elemPath := fmt.Sprintf("%s[%d]", path, i)
+		elemValue, err := e.bindStructElem(field.Type.Elem(), fmt.Sprintf("%s_%d", keyPrefix, i), elemPath)
+		if err != nil {
+			errs = errs.add(err, elemPath)
+			continue
 		}
+		sliceValue = reflect.Append(sliceValue, elemValue)
+	}
+	if len(errs) > 0 {
+		return reflect.Value{}, errs
+	}
+	return sliceValue, nil
+}
+
+// bindStructMap binds a map of structs from named keys, eg: DB_PRIMARY_HOST, DB_REPLICA_HOST...
+// the names are taken from env_keys when present, otherwise from the keys with the prefix ending with
+// a key of the element fields, the name is the part in between and may contain '_', eg: DB_US_EAST_HOST
+// The map is empty when no key matches
+func (e *EnvManager) bindStructMap(field reflect.StructField, keyPrefix, path string) (reflect.Value, error) {
+	if field.Type.Key().Kind() != reflect.String {
+		return reflect.Value{}, newUnSupportedTypeError(field.Name, field.Type.String())
+	}
+
+	names := []string{}
+	if keys := field.Tag.Get(STRUCT_TAG_KEYS); keys != "" && keys != STRUCT_KEYWORD_ALL {
+		names = strings.Split(keys, getDelim(field))
+	} else {
+		fieldKeys := e.structFieldKeys(field.Type.Elem(), "")
+		for _, key := range e.source().Keys() {
+			rest, found := strings.CutPrefix(key, keyPrefix+"_")
+			if name := elemName(rest, fieldKeys); found && name != "" && !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+		slices.Sort(names)
+	}
+
+	mapValue := reflect.MakeMap(field.Type)
+	var errs EnvErrors
+	for _, name := range names {
+		elemPath := fmt.Sprintf("%s[%s]", path, name)
+		elemValue, err := e.bindStructElem(field.Type.Elem(), keyPrefix+"_"+name, elemPath)
+		if err != nil {
+			errs = errs.add(err, elemPath)
+			continue
+		}
+		mapValue.SetMapIndex(reflect.ValueOf(name).Convert(field.Type.Key()), elemValue)
+	}
+	if len(errs) > 0 {
+		return reflect.Value{}, errs
 	}
 	return mapValue, nil
 }
 
+// fieldKey is the env key of a struct field relative to the struct prefix,
+// the keys of collections are prefixes of the keys of their elements
+type fieldKey struct {
+	key    string
+	prefix bool
+}
+
+// structFieldKeys returns the keys the fields of a struct, or pointer to struct, are bound from
+func (e *EnvManager) structFieldKeys(structType reflect.Type, prefix string) []fieldKey {
+	if structType.Kind() == reflect.Pointer {
+		structType = structType.Elem()
+	}
+	keys := []fieldKey{}
+	for i := range structType.NumField() {
+		field := structType.Field(i)
+		envTag := strings.Split(field.Tag.Get(STRUCT_TAG_ENV), ",")
+		if slices.Contains(envTag, STRUCT_KEYWORD_IGNORE) {
+			continue
+		}
+		fieldPrefix := prefix
+		if tagPrefix := field.Tag.Get(STRUCT_TAG_PREFIX); tagPrefix != "" {
+			fieldPrefix = joinPrefix(prefix, tagPrefix)
+		}
+		envKey := joinPrefix(fieldPrefix, e.getNameFromTag(envTag, field.Name))
+		opts := e.getCastOptions(field)
+		mapKeys := field.Tag.Get(STRUCT_TAG_KEYS)
+
+		switch kind := field.Type.Kind(); {
+		case field.Tag.Get(STRUCT_TAG_FORMAT) != "" || hasCustomCast(field.Type, opts):
+			keys = append(keys, fieldKey{key: envKey})
+		case (kind == reflect.Slice || kind == reflect.Map) && isStructType(field.Type.Elem(), opts):
+			keys = append(keys, fieldKey{key: envKey + "_", prefix: true})
+		case kind == reflect.Map && strings.HasSuffix(mapKeys, STRUCT_KEYWORD_ALL):
+			keys = append(keys, fieldKey{key: joinPrefix(fieldPrefix, strings.TrimSuffix(mapKeys, STRUCT_KEYWORD_ALL)), prefix: true})
+		case kind == reflect.Map && mapKeys != "":
+			for _, key := range strings.Split(mapKeys, opts.delim) {
+				keys = append(keys, fieldKey{key: joinPrefix(fieldPrefix, key)})
+			}
+		case isStructType(field.Type, opts):
+			keys = append(keys, e.structFieldKeys(field.Type, fieldPrefix)...)
+		default:
+			keys = append(keys, fieldKey{key: envKey})
+		}
+	}
+	return keys
+}
+
+// returns the name of the map element of rest, the part of a key after the map prefix,
+// eg: US_EAST for US_EAST_HOST, and "" when rest is not the key of an element field.
+// The shortest name wins so keys of nested elements, eg: US_ZONES_0_HOST, give the outer name
+func elemName(rest string, fieldKeys []fieldKey) string {
+	name := ""
+	for _, fk := range fieldKeys {
+		candidate := ""
+		if fk.prefix {
+			if i := strings.Index(rest, "_"+fk.key); i > 0 {
+				candidate = rest[:i]
+			}
+		} else if trimmed, found := strings.CutSuffix(rest, "_"+fk.key); found {
+			candidate = trimmed
+		}
+		if candidate != "" && (name == "" || len(candidate) < len(name)) {
+			name = candidate
+		}
+	}
+	return name
+}
+
+// binds a new struct, or pointer to struct, of elemType for the elements of slices and maps
+func (e *EnvManager) bindStructElem(elemType reflect.Type, prefix, path string) (reflect.Value, error) {
+	if elemType.Kind() == reflect.Pointer {
+		structPtr := reflect.New(elemType.Elem())
+		return structPtr, e.bindEnvWithPrefix(structPtr.Interface(), prefix, path)
+	}
+	structPtr := reflect.New(elemType)
+	return structPtr.Elem(), e.bindEnvWithPrefix(structPtr.Interface(), prefix, path)
+}
+
 func (e *EnvManager) setField(i int, key string, ptr any, value reflect.Value) {
 	field := reflect.ValueOf(ptr).Elem().Field(i)
 	field.Set(value)
@@ -183,10 +368,8 @@ func (e *EnvManager) setField(i int, key string, ptr any, value reflect.Value) {
 }
 
 func (e *EnvManager) getEnvValue(prefix, key string, defValue *string) (string, string, error) {
-	if prefix != "" {
-		key = prefix + "_" + key
-	}
-	values, exists := os.LookupEnv(key)
+	key = joinPrefix(prefix, key)
+	values, exists := e.source().Lookup(key)
 	if !exists {
 		if defValue != nil {
 			return key, *defValue, nil
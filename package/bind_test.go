@@ -1,8 +1,13 @@
 package env_manager
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
 	"slices"
+	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 )
 
@@ -27,7 +32,7 @@ func TestParsingForComplexFile(t *testing.T) {
 	assertEqual(t, len(envMap), 25, "Invalid number of env variables parsed")
 	assertEqual(t, envMap["APP_NAME"], "MultiLineApp", "Invalid value for variable APP_NAME from env")
 
-	assertEqual(t, envMap["WELCOME_MESSAGE"], `Welcome to $APP_NAME!
+	assertEqual(t, envMap["WELCOME_MESSAGE"], `Welcome to MultiLineApp!
 Environment: production
 Running at http://127.0.0.1:8080`, "Invalid value for variable WELCOME_MESSAGE from env")
 
@@ -36,6 +41,11 @@ id SERIAL PRIMARY KEY,
 name TEXT NOT NULL,
 email TEXT UNIQUE NOT NULL
 );`, "Invalid value for variable INIT_SQL from env")
+
+	assertEqual(t, envMap["FULL_SIGNATURE"], `Thanks,
+MultiLineApp Team
+Contact: support@127.0.0.1
+Generated by MultiLineApp v1.0.0`, "Invalid value for variable FULL_SIGNATURE from env")
 	t.Log("Env variables: ", len(envMap))
 }
 
@@ -115,12 +125,417 @@ func TestBindEnvForComplexData(t *testing.T) {
 	}
 	envManger.LoadEnv()
 
-	envManger.BindEnv(envBinder)
+	if err := envManger.BindEnv(envBinder); err != nil {
+		t.Fatal(err)
+	}
 	assertEqual(t, *envBinder.AppName, "MultiLineApp", "Invalid AppName")
 	assertEqual(t, envBinder.Version, "1.0.0", "Invalid Version")
 	assertCondition(t, slices.Equal(envBinder.Options, []string{"min", "med", "max"}), "Invalid Options")
 	assertEqual(t, envBinder.AppCount, 69, "Invalid AppCount")
-	t.Log(envBinder)
-	t.Log(*envBinder.TLS)
-	t.Error()
+	assertEqual(t, envBinder.Expiry, 3000*time.Second, "Invalid Expiry")
+	assertEqual(t, envBinder.Email.Port, 2525, "Invalid Email.Port")
+	assertCondition(t, envBinder.TLS != nil, "TLS struct pointer must be set")
+}
+
+type TestBindEnvFormatStruct struct {
+	FeatureFlags map[string]bool `env_format:"json"`
+	RateLimits   []struct {
+		Path string `json:"path"`
+		RPS  int    `json:"rps"`
+	} `env_format:"json"`
+	Replicas map[string]int `env_format:"yaml"`
+	Backends []struct {
+		Host string `yaml:"host"`
+		Port int    `yaml:"port"`
+	} `env_format:"yaml"`
+}
+
+func TestBindEnvFormat(t *testing.T) {
+	content := `FEATURE_FLAGS='{"dark_mode": true, "beta": false}'
+RATE_LIMITS='[{"path": "/api", "rps": 100}, {"path": "/auth", "rps": 5}]'
+REPLICAS="{api: 3, worker: 2}"
+BACKENDS="
+- host: a.internal
+  port: 80
+- host: b.internal
+  port: 81
+"`
+	envBinder := new(TestBindEnvFormatStruct)
+	if err := newTestManager(t, newTestEnvFile(t, content)).SetBindMode(BIND_FILE_WINS).BindEnv(envBinder); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, envBinder.FeatureFlags["dark_mode"], true, "Invalid FeatureFlags")
+	assertEqual(t, envBinder.RateLimits[1].RPS, 5, "Invalid RateLimits")
+	assertEqual(t, envBinder.Replicas["worker"], 2, "Invalid yaml Replicas")
+	assertEqual(t, len(envBinder.Backends), 2, "Invalid number of yaml Backends")
+	assertEqual(t, envBinder.Backends[1].Port, 81, "Invalid yaml Backends")
+}
+
+type TestBindEnvFormatErrorStruct struct {
+	FeatureFlags map[string]bool `env_format:"json"`
+	Settings     map[string]any  `env_format:"toml"`
+}
+
+func TestBindEnvFormatError(t *testing.T) {
+	content := "FEATURE_FLAGS='{\"dark_mode\": yes}'\nSETTINGS=debug: true"
+	err := newTestManager(t, newTestEnvFile(t, content)).SetMode(SILENT).SetBindMode(BIND_FILE_WINS).BindEnv(new(TestBindEnvFormatErrorStruct))
+	var envErrs EnvErrors
+	if !errors.As(err, &envErrs) {
+		t.Fatalf("Expected EnvErrors got %v", err)
+	}
+	assertEqual(t, len(envErrs), 2, "Invalid number of errors")
+	assertEqual(t, envErrs[0].Type, TYPE_CAST_ERROR, "Decode errors must be type cast errors")
+	assertCondition(t, strings.Contains(envErrs[0].Error(), "FEATURE_FLAGS"), "Decode errors must name the key")
+	assertEqual(t, envErrs[1].Type, INVALID_USAGE_ERROR, "Unregistered formats must be invalid usage errors")
+
+	settings := struct {
+		Settings map[string]string `env_format:"kv"`
+	}{}
+	envManager := newTestManager(t, newTestEnvFile(t, content)).SetBindMode(BIND_FILE_WINS).
+		RegisterFormat("kv", func(data []byte, v any) error {
+			key, value, _ := strings.Cut(string(data), ": ")
+			*(v.(*map[string]string)) = map[string]string{key: value}
+			return nil
+		})
+	if err := envManager.BindEnv(&settings); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, settings.Settings["debug"], "true", "Registered formats must be used")
+}
+
+type TestBindEnvReturnsErrorStruct struct {
+	AppName    string
+	MissingKey string `env:"GO_ENV_MANAGER_MISSING_KEY"`
+}
+
+func TestBindEnvReturnsError(t *testing.T) {
+	envBinder := new(TestBindEnvReturnsErrorStruct)
+	envManager := newTestManager(t, "../test_data/simple.env").SetMode(SILENT)
+	if err := envManager.LoadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := envManager.BindEnv(envBinder)
+	var envErr *EnvError
+	assertCondition(t, errors.As(err, &envErr), "BindEnv must return an *EnvError")
+	assertEqual(t, envErr.Type, KEY_NOT_FOUND_ERROR, "Missing key must be a key not found error")
+
+	err = envManager.BindEnv(TestBindEnvReturnsErrorStruct{})
+	assertCondition(t, errors.As(err, &envErr), "BindEnv must reject non pointer values")
+	assertEqual(t, envErr.Type, INVALID_USAGE_ERROR, "Non pointer value must be an invalid usage error")
+}
+
+type TestBindEnvAggregatesErrorsStruct struct {
+	AppName string
+	AppPort bool   `env:"APP_PORT"`
+	Missing string `env:"GO_ENV_MANAGER_MISSING_KEY"`
+	Email   struct {
+		Port int `env:"NAME"`
+	} `env_prefix:"APP"`
+}
+
+func TestBindEnvAggregatesErrors(t *testing.T) {
+	envBinder := new(TestBindEnvAggregatesErrorsStruct)
+	envManager := newTestManager(t, "../test_data/simple.env").SetMode(SILENT)
+	if err := envManager.LoadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := envManager.BindEnv(envBinder)
+	var envErrs EnvErrors
+	if !errors.As(err, &envErrs) {
+		t.Fatalf("BindEnv must return EnvErrors, got %v", err)
+	}
+	assertEqual(t, len(envErrs), 3, "All the failing fields must be reported")
+	assertEqual(t, envErrs[0].Field, "TestBindEnvAggregatesErrorsStruct.AppPort", "Invalid field path for AppPort")
+	assertEqual(t, envErrs[1].Field, "TestBindEnvAggregatesErrorsStruct.Missing", "Invalid field path for Missing")
+	assertEqual(t, envErrs[2].Field, "TestBindEnvAggregatesErrorsStruct.Email.Port", "Invalid field path for Email.Port")
+	assertCondition(t, errors.Is(err, &EnvError{Type: KEY_NOT_FOUND_ERROR}), "errors.Is must match the missing key")
+	assertCondition(t, errors.Is(err, &EnvError{Type: TYPE_CAST_ERROR}), "errors.Is must match the cast error")
+	assertCondition(t, !errors.Is(err, &EnvError{}), "errors.Is must not match a target without a type")
+	assertEqual(t, envBinder.AppName, "MyCoolApp", "Valid fields must still be bound")
+}
+
+type TestBindModeStruct struct {
+	TenantName string `env:"GO_ENV_MANAGER_TENANT"`
+	TenantPort int    `env:"GO_ENV_MANAGER_TENANT_PORT"`
+}
+
+func TestBindEnvFromFileWithoutLoading(t *testing.T) {
+	first := newTestManager(t, newTestEnvFile(t, "GO_ENV_MANAGER_TENANT=first\nGO_ENV_MANAGER_TENANT_PORT=1")).SetBindMode(BIND_FILE_WINS)
+	second := newTestManager(t, newTestEnvFile(t, "GO_ENV_MANAGER_TENANT=second\nGO_ENV_MANAGER_TENANT_PORT=2")).SetBindMode(BIND_FILE_WINS)
+
+	firstBinder, secondBinder := new(TestBindModeStruct), new(TestBindModeStruct)
+	if err := first.BindEnv(firstBinder); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.BindEnv(secondBinder); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, firstBinder.TenantName, "first", "First manager must bind its own file")
+	assertEqual(t, secondBinder.TenantPort, 2, "Second manager must bind its own file")
+	_, leaked := os.LookupEnv("GO_ENV_MANAGER_TENANT")
+	assertCondition(t, !leaked, "Binding from the env file must not modify the process environment")
+}
+
+func TestBindEnvPrecedence(t *testing.T) {
+	t.Setenv("GO_ENV_MANAGER_TENANT", "from-os")
+	file := newTestEnvFile(t, "GO_ENV_MANAGER_TENANT=from-file\nGO_ENV_MANAGER_TENANT_PORT=1")
+
+	fileWins := new(TestBindModeStruct)
+	if err := newTestManager(t, file).SetBindMode(BIND_FILE_WINS).BindEnv(fileWins); err != nil {
+		t.Fatal(err)
+	}
+	osWins := new(TestBindModeStruct)
+	if err := newTestManager(t, file).SetBindMode(BIND_OS_WINS).BindEnv(osWins); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, fileWins.TenantName, "from-file", "File value must win with BIND_FILE_WINS")
+	assertEqual(t, osWins.TenantName, "from-os", "OS value must win with BIND_OS_WINS")
+	assertEqual(t, osWins.TenantPort, 1, "Keys missing in the OS must fall back to the file")
+}
+
+type TestBindEnvFromSourcesStruct struct {
+	DBHost     string `env:"DB_HOST"`
+	DBPassword string `env:"DB_PASSWORD"`
+	DBURL      string `env:"DB_URL"`
+}
+
+func TestBindEnvFromSources(t *testing.T) {
+	secrets := t.TempDir()
+	if err := os.WriteFile(filepath.Join(secrets, "DB_PASSWORD"), []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	file := newTestEnvFile(t, "DB_URL=postgres://${DB_HOST}:5432\nDB_PASSWORD=from-file")
+	_, err := NewFileSource(file)
+	assertCondition(t, err != nil, "NewFileSource must fail when a substituted variable is missing")
+
+	envManager := newTestManager(t, file)
+	envManager.SetSources(
+		NewDirSource(secrets),
+		envManager.FileSource(),
+		NewMapSource(map[string]string{"DB_HOST": "db.internal"}),
+	)
+
+	envBinder := new(TestBindEnvFromSourcesStruct)
+	if err := envManager.BindEnv(envBinder); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, envBinder.DBHost, "db.internal", "DB_HOST must come from the map source")
+	assertEqual(t, envBinder.DBPassword, "s3cret", "DB_PASSWORD must come from the dir source first")
+	assertEqual(t, envBinder.DBURL, "postgres://db.internal:5432", "DB_URL must be substituted through the sources")
+	_, leaked := os.LookupEnv("DB_URL")
+	assertCondition(t, !leaked, "Binding from sources must not modify the process environment")
+}
+
+type TestBackend struct {
+	Host string
+	Port int
+}
+
+type TestBindStructCollectionsStruct struct {
+	Backends []TestBackend
+	Replicas []*TestBackend `env:"REPLICA"`
+	DB       map[string]TestBackend
+	Caches   map[string]*TestBackend `env:"CACHE" env_keys:"LOCAL"`
+}
+
+func TestBindStructCollections(t *testing.T) {
+	content := "BACKENDS_0_HOST=a.internal\nBACKENDS_0_PORT=80\nBACKENDS_1_HOST=b.internal\nBACKENDS_1_PORT=81\n" +
+		"REPLICA_0_HOST=r.internal\nREPLICA_0_PORT=90\n" +
+		"DB_PRIMARY_HOST=db1\nDB_PRIMARY_PORT=5432\nDB_REPLICA_HOST=db2\nDB_REPLICA_PORT=5433\n" +
+		"CACHE_LOCAL_HOST=localhost\nCACHE_LOCAL_PORT=6379\nCACHE_REMOTE_HOST=redis"
+	envBinder := new(TestBindStructCollectionsStruct)
+	if err := newTestManager(t, newTestEnvFile(t, content)).SetBindMode(BIND_FILE_WINS).BindEnv(envBinder); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, len(envBinder.Backends), 2, "Invalid number of Backends")
+	assertEqual(t, envBinder.Backends[1], TestBackend{"b.internal", 81}, "Invalid Backends element")
+	assertEqual(t, *envBinder.Replicas[0], TestBackend{"r.internal", 90}, "Invalid Replicas element")
+	assertEqual(t, len(envBinder.DB), 2, "Invalid number of DB entries")
+	assertEqual(t, envBinder.DB["REPLICA"], TestBackend{"db2", 5433}, "Invalid DB entry")
+	assertEqual(t, len(envBinder.Caches), 1, "env_keys must limit the map entries")
+	assertEqual(t, envBinder.Caches["LOCAL"].Port, 6379, "Invalid Caches entry")
+
+	content = strings.Replace(content, "BACKENDS_1_PORT=81", "BACKENDS_1_PORT=http", 1)
+	err := newTestManager(t, newTestEnvFile(t, content)).SetMode(SILENT).SetBindMode(BIND_FILE_WINS).BindEnv(new(TestBindStructCollectionsStruct))
+	var envErr *EnvError
+	if !errors.As(err, &envErr) {
+		t.Fatalf("Expected EnvError got %v", err)
+	}
+	assertEqual(t, envErr.Field, "TestBindStructCollectionsStruct.Backends[1].Port", "Invalid field path for slice element")
+}
+
+type TestRegion struct {
+	Host  string
+	Port  int `env_def:"443"`
+	Zones []TestBackend
+	Tags  map[string]string `env_keys:"TAG_*"`
+}
+
+type TestBindStructMapNamesStruct struct {
+	Regions map[string]*TestRegion
+	Default string `env:"REGIONS_DEFAULT"`
+}
+
+func TestBindStructMapNames(t *testing.T) {
+	content := "REGIONS_US_EAST_HOST=a\nREGIONS_EU_HOST=b\nREGIONS_EU_PORT=8443\nREGIONS_DEFAULT=eu\n" +
+		"REGIONS_AP_SOUTH_1_ZONES_0_HOST=z\nREGIONS_AP_SOUTH_1_ZONES_0_PORT=1\nREGIONS_AP_SOUTH_1_HOST=c\nREGIONS_SA_TAG_TEAM=core\nREGIONS_SA_HOST=d"
+	envBinder := new(TestBindStructMapNamesStruct)
+	if err := newTestManager(t, newTestEnvFile(t, content)).SetBindMode(BIND_FILE_WINS).BindEnv(envBinder); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, len(envBinder.Regions), 4, "Sibling keys must not be map elements")
+	assertEqual(t, envBinder.Regions["US_EAST"].Host, "a", "Names must be able to contain '_'")
+	assertEqual(t, envBinder.Regions["EU"].Port, 8443, "Invalid EU element")
+	assertEqual(t, envBinder.Regions["AP_SOUTH_1"].Zones[0].Host, "z", "Names must be found from nested collections")
+	assertEqual(t, envBinder.Regions["SA"].Tags["REGIONS_SA_TAG_TEAM"], "core", "Names must be found from wildcard maps")
+	assertEqual(t, envBinder.Default, "eu", "Sibling keys must still be bound")
+}
+
+type TestBindEmptyStructCollectionsStruct struct {
+	Backends []TestBackend
+	DB       map[string]TestBackend
+	Required []TestBackend `env:"REQUIRED" env_validate:"required"`
+}
+
+func TestBindEmptyStructCollections(t *testing.T) {
+	manager := newTestManager(t, newTestEnvFile(t, "REQUIRED_0_HOST=a.internal\nREQUIRED_0_PORT=80")).SetBindMode(BIND_FILE_WINS)
+	envBinder := new(TestBindEmptyStructCollectionsStruct)
+	if err := manager.BindEnv(envBinder); err != nil {
+		t.Fatalf("Missing struct collections must be empty, got %v", err)
+	}
+	assertEqual(t, len(envBinder.Backends), 0, "Slices without keys must be empty")
+	assertEqual(t, len(envBinder.DB), 0, "Maps without keys must be empty")
+
+	err := newTestManager(t, newTestEnvFile(t, "")).SetMode(SILENT).SetBindMode(BIND_FILE_WINS).BindEnv(new(TestBindEmptyStructCollectionsStruct))
+	assertCondition(t, errors.Is(err, &EnvError{Type: VALIDATION_ERROR}), "required must reject empty struct collections")
+}
+
+type testRegion string
+
+type TestMapKeyTransformStruct struct {
+	Users   map[string]string     `env_keys:"USER_*" env_map_key:"strip,lower"`
+	Shards  map[int]string        `env_keys:"SHARD_*" env_map_key:"strip"`
+	Limits  map[string]int        `env_keys:"MAX_OPEN_CONNS,MAX_IDLE_CONNS" env_map_key:"camel"`
+	Regions map[testRegion]string `env_keys:"*" env_map_key:"strip,lower" env_prefix:"GO_ENV_MANAGER_REGION"`
+	Full    map[string]string     `env_keys:"USER_*"`
+}
+
+func TestMapKeyTransform(t *testing.T) {
+	t.Setenv("USER_GUEST", "from-os")
+	t.Setenv("GO_ENV_MANAGER_REGION_EU", "eu-west-1")
+	content := "USER_ADMIN=root\nSHARD_0=alpha\nSHARD_1=beta\nMAX_OPEN_CONNS=10\nMAX_IDLE_CONNS=2\nGO_ENV_MANAGER_REGION_US=us-east-1"
+	envBinder := new(TestMapKeyTransformStruct)
+	if err := newTestManager(t, newTestEnvFile(t, content)).SetBindMode(BIND_FILE_WINS).BindEnv(envBinder); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, envBinder.Users["admin"], "root", "Prefix must be stripped and the key lowercased")
+	assertEqual(t, envBinder.Users["guest"], "from-os", "Wildcards must match the OS environment")
+	assertEqual(t, envBinder.Shards[1], "beta", "Stripped keys must be cast to the map key type")
+	assertEqual(t, envBinder.Limits["maxIdleConns"], 2, "Keys must be converted to camelCase")
+	assertEqual(t, envBinder.Regions["us"], "us-east-1", "Wildcards must match under the field prefix")
+	assertEqual(t, envBinder.Regions["eu"], "eu-west-1", "Wildcards must match the OS environment under the field prefix")
+	assertEqual(t, envBinder.Full["USER_ADMIN"], "root", "Keys must be kept as is by default")
+}
+
+type TestMapKeyCastErrorStruct struct {
+	Shards map[int]string `env_keys:"SHARD_*" env_map_key:"strip"`
+	Limits map[string]int `env_keys:"MAX_OPEN_CONNS"`
+}
+
+func TestMapKeyCastErrors(t *testing.T) {
+	content := "SHARD_k=alpha\nMAX_OPEN_CONNS=many"
+	err := newTestManager(t, newTestEnvFile(t, content)).SetMode(SILENT).SetBindMode(BIND_FILE_WINS).BindEnv(new(TestMapKeyCastErrorStruct))
+	var envErrs EnvErrors
+	if !errors.As(err, &envErrs) {
+		t.Fatalf("Expected EnvErrors got %v", err)
+	}
+	assertEqual(t, len(envErrs), 2, "Invalid keys and values must be reported")
+	for _, envErr := range envErrs {
+		assertEqual(t, envErr.Type, TYPE_CAST_ERROR, "Invalid keys and values must be type cast errors")
+		assertEqual(t, strings.Count(envErr.Error(), "cannot be casted"), 1, "Cast errors must not be wrapped again, got "+envErr.Error())
+		assertCondition(t, envErr.Origin != nil, "Cast errors must cite the origin of the key")
+	}
+	assertCondition(t, strings.Contains(envErrs[0].Error(), "k cannot be casted to type int"), "Invalid key error, got "+envErrs[0].Error())
+	assertCondition(t, strings.Contains(envErrs[1].Error(), "many cannot be casted to type int"), "Invalid value error, got "+envErrs[1].Error())
+}
+
+type TestBindOriginStruct struct {
+	Port    int    `env:"GO_ENV_MANAGER_ORIGIN_PORT"`
+	Mode    string `env:"GO_ENV_MANAGER_ORIGIN_MODE" env_validate:"oneof=dev|prod"`
+	Timeout int    `env:"GO_ENV_MANAGER_ORIGIN_TIMEOUT" env_def:"abc"`
+}
+
+func TestBindEnvErrorsCiteOrigin(t *testing.T) {
+	base := newTestEnvFile(t, "GO_ENV_MANAGER_ORIGIN_PORT=80\nGO_ENV_MANAGER_ORIGIN_MODE=dev")
+	local := newTestEnvFile(t, "# local overrides\nGO_ENV_MANAGER_ORIGIN_PORT=eighty\nGO_ENV_MANAGER_ORIGIN_MODE=\"test\"")
+	manager, err := NewEnvManager(base, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = manager.SetBindMode(BIND_FILE_WINS).BindEnv(new(TestBindOriginStruct))
+
+	var errs EnvErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected EnvErrors got %v", err)
+	}
+	assertEqual(t, len(errs), 3, "Every invalid field must be reported")
+	assertEqual(t, *errs[0].Origin, Origin{local, 2, 28, 0}, "Cast errors must cite the file that declared the value")
+	assertEqual(t, *errs[1].Origin, Origin{local, 3, 28, '"'}, "Validation errors must cite the file that declared the value")
+	assertCondition(t, errs[2].Origin == nil, "Default values have no origin")
+	assertCondition(t, strings.Contains(errs[0].Error(), " at "+local+":2:28"), "Error message must contain the origin, got "+errs[0].Error())
+
+	origin, ok := manager.GetOrigin("GO_ENV_MANAGER_ORIGIN_MODE")
+	assertCondition(t, ok && origin.File == local, "GetOrigin must return the origin of the value")
+}
+
+func TestEnvManagerFS(t *testing.T) {
+	manager := newTestFSManager(t, map[string]string{
+		".env":                   "@import config/base.env\nGO_ENV_MANAGER_TENANT_PORT=8080",
+		"config/base.env":        "GO_ENV_MANAGER_TENANT=${GO_ENV_MANAGER_FS_NAME}\n#include names.env",
+		"config/names.env":       "GO_ENV_MANAGER_FS_NAME=embedded",
+		"config/unused/file.env": "UNUSED=1",
+	})
+
+	binder := new(TestBindModeStruct)
+	if err := manager.SetBindMode(BIND_FILE_WINS).BindEnv(binder); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, binder.TenantName, "embedded", "Included files must be read from the file system")
+	assertEqual(t, binder.TenantPort, 8080, "The default .env file must be read from the file system")
+	origin, _ := manager.GetOrigin("GO_ENV_MANAGER_FS_NAME")
+	assertEqual(t, origin.File, "config/names.env", "Origins must use the paths of the file system")
+
+	_, err := NewEnvManagerFS(fstest.MapFS{}, "missing.env")
+	var envErr *EnvError
+	assertCondition(t, errors.As(err, &envErr) && envErr.Type == CONFIG_ERROR, "Missing files must be config errors")
+	_, err = NewEnvManagerFS(nil)
+	assertCondition(t, errors.As(err, &envErr) && envErr.Type == INVALID_USAGE_ERROR, "A nil file system must be rejected")
+}
+
+func TestEnvManagerFSRebind(t *testing.T) {
+	fsys := fstest.MapFS{".env": {Data: []byte("GO_ENV_MANAGER_TENANT=first\nGO_ENV_MANAGER_TENANT_PORT=1")}}
+	manager, err := NewEnvManagerFS(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watched, err := NewWatched[TestBindModeStruct](manager.SetBindMode(BIND_FILE_WINS))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := manager.fileStates()
+	fsys[".env"] = &fstest.MapFile{Data: []byte("GO_ENV_MANAGER_TENANT=second\nGO_ENV_MANAGER_TENANT_PORT=1")}
+	assertCondition(t, !slices.EqualFunc(before, manager.fileStates(), fileState.equal), "Changes of the file system must be detected")
+	changes, err := manager.rebind(watched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, len(changes), 1, "Only the changed key must be reported")
+	assertEqual(t, watched.Load().TenantName, "second", "Rebinding must read the file system again")
 }
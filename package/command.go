@@ -0,0 +1,122 @@
+package env_manager
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const DEFAULT_COMMAND_TIMEOUT = 5 * time.Second
+
+// CommandExecutor runs the commands of $(...) substitutions, tests can inject a fake one
+type CommandExecutor interface {
+	// Execute runs command in dir and returns its standard output
+	Execute(ctx context.Context, command, dir string) (string, error)
+}
+
+// NewShellExecutor returns an executor that runs the commands with sh -c
+func NewShellExecutor() CommandExecutor {
+	return shellExecutor{}
+}
+
+type shellExecutor struct{}
+
+func (shellExecutor) Execute(ctx context.Context, command, dir string) (string, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return string(output), err
+}
+
+// EnableCommandSubstitution lets the env files run commands with $(...), eg: GIT_SHA=$(git rev-parse HEAD)
+// It is off by default so untrusted files can't run commands, a nil executor uses NewShellExecutor
+func (e *EnvManager) EnableCommandSubstitution(executor CommandExecutor) *EnvManager {
+	if executor == nil {
+		executor = NewShellExecutor()
+	}
+	e.commands.executor = executor
+	return e
+}
+
+// SetCommandTimeout sets how long a single $(...) command may run
+func (e *EnvManager) SetCommandTimeout(timeout time.Duration) *EnvManager {
+	if timeout <= 0 {
+		timeout = DEFAULT_COMMAND_TIMEOUT
+	}
+	e.commands.timeout = timeout
+	return e
+}
+
+// SetCommandDir sets the working directory of the $(...) commands, by default they run in the directory
+// of the env file, or in the process working directory for env files read from a fs.FS
+func (e *EnvManager) SetCommandDir(dir string) *EnvManager {
+	e.commands.dir = dir
+	return e
+}
+
+// commandRunner holds the command substitution settings, a nil executor disables it
+type commandRunner struct {
+	executor CommandExecutor
+	timeout  time.Duration
+	dir      string
+}
+
+func (c commandRunner) enabled() bool {
+	return c.executor != nil
+}
+
+// run executes command for the env file, trailing newlines are removed from the output like in shells.
+// Without a file the command runs in the configured directory or the process working directory
+func (c commandRunner) run(command, file string) (string, error) {
+	dir := c.dir
+	if dir == "" && file != "" {
+		dir = filepath.Dir(file)
+	}
+	timeout := c.timeout
+	if timeout <= 0 {
+		timeout = DEFAULT_COMMAND_TIMEOUT
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	output, err := c.executor.Execute(ctx, command, dir)
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("timed out after %v", timeout)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(output, "\n"), nil
+}
+
+// returns the index of the ')' closing the command starting at start, nested and quoted parentheses are skipped
+func findClosingParen(str string, start int) int {
+	nesting := 0
+	quote := byte(0)
+	for i := start; i < len(str); i++ {
+		switch {
+		case quote != 0:
+			if str[i] == quote {
+				quote = 0
+			}
+		case str[i] == '\'' || str[i] == '"':
+			quote = str[i]
+		case str[i] == '\\':
+			i++
+		case str[i] == '(':
+			nesting++
+		case str[i] == ')':
+			if nesting == 0 {
+				return i
+			}
+			nesting--
+		}
+	}
+	return -1
+}
@@ -0,0 +1,93 @@
+package env_manager
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeExecutor returns canned outputs and records the commands it was asked to run
+type fakeExecutor struct {
+	outputs  map[string]string
+	commands []string
+	dirs     []string
+}
+
+func (f *fakeExecutor) Execute(ctx context.Context, command, dir string) (string, error) {
+	f.commands = append(f.commands, command)
+	f.dirs = append(f.dirs, dir)
+	if command == "sleep" {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	output, ok := f.outputs[command]
+	if !ok {
+		return "", errors.New("command not found")
+	}
+	return output, nil
+}
+
+func TestCommandSubstitutionIsOffByDefault(t *testing.T) {
+	manager := newTestManager(t, newTestEnvFile(t, "GIT_SHA=$(git rev-parse HEAD)"))
+
+	assertEqual(t, manager.GetEnvMap()["GIT_SHA"], "$(git rev-parse HEAD)", "Commands must not run unless enabled")
+}
+
+func TestCommandSubstitution(t *testing.T) {
+	file := newTestEnvFile(t, `REF=HEAD
+GIT_SHA=$(git rev-parse HEAD)
+LABEL="build-$(git rev-parse HEAD)-${REF}"
+SINGLE='$(git rev-parse HEAD)'`)
+	executor := &fakeExecutor{outputs: map[string]string{"git rev-parse HEAD": "abc123\n"}}
+	manager := newTestManager(t, file).EnableCommandSubstitution(executor)
+
+	env := manager.GetEnvMap()
+	assertEqual(t, env["GIT_SHA"], "abc123", "Command output must be substituted without trailing newlines")
+	assertEqual(t, env["LABEL"], "build-abc123-HEAD", "Commands must be substituted in double quotes")
+	assertEqual(t, env["SINGLE"], "$(git rev-parse HEAD)", "Single quoted values must stay literal")
+	assertEqual(t, len(executor.commands), 2, "Only unquoted and double quoted commands must run")
+	assertEqual(t, executor.dirs[0], filepath.Dir(file), "Commands must run in the directory of the env file by default")
+
+	manager.SetCommandDir("/tmp")
+	manager.GetEnvMap()
+	assertEqual(t, executor.dirs[len(executor.dirs)-1], "/tmp", "Commands must run in the configured directory")
+}
+
+func TestCommandSubstitutionFS(t *testing.T) {
+	files := map[string]string{"config/app.env": "GIT_SHA=$(git rev-parse HEAD)\nGREETING=$(echo hello)"}
+	executor := &fakeExecutor{outputs: map[string]string{"git rev-parse HEAD": "abc123", "echo hello": "hello"}}
+	manager := newTestFSManager(t, files, "config/app.env").EnableCommandSubstitution(executor)
+
+	assertEqual(t, manager.GetEnvMap()["GIT_SHA"], "abc123", "Commands of fs.FS files must be substituted")
+	assertEqual(t, executor.dirs[0], "", "Commands of fs.FS files must run in the process working directory")
+
+	// the directory of the file does not exist on disk
+	manager = newTestFSManager(t, files, "config/app.env").EnableCommandSubstitution(nil)
+	if err := manager.LoadEnv(); err != nil {
+		t.Fatalf("Commands of fs.FS files must not run in the directory of the file, got %v", err)
+	}
+	assertEqual(t, manager.GetEnvMap()["GREETING"], "hello", "Shell commands of fs.FS files must be substituted")
+}
+
+func TestCommandSubstitutionErrors(t *testing.T) {
+	executor := &fakeExecutor{}
+	manager := newTestManager(t, newTestEnvFile(t, "A=1\nB=$(missing)")).EnableCommandSubstitution(executor)
+	err := manager.LoadEnv()
+
+	var envErr *EnvError
+	if !errors.As(err, &envErr) {
+		t.Fatalf("Expected EnvError got %v", err)
+	}
+	assertEqual(t, envErr.Type, CONFIG_ERROR, "Failed commands must be config errors")
+	assertCondition(t, strings.Contains(err.Error(), ".env:2") && strings.Contains(err.Error(), "command not found"),
+		"Error must point to the command, got "+err.Error())
+
+	manager = newTestManager(t, newTestEnvFile(t, "SLOW=$(sleep)")).
+		EnableCommandSubstitution(executor).
+		SetCommandTimeout(10 * time.Millisecond)
+	err = manager.LoadEnv()
+	assertCondition(t, err != nil && strings.Contains(err.Error(), "timed out"), "Slow commands must time out")
+}
@@ -0,0 +1,79 @@
+package env_manager
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConverterFunc parses the raw env value into a value of the type it is registered for
+type ConverterFunc func(value string) (any, error)
+
+// builtinConverterFunc is a ConverterFunc that can read the cast options of the field
+type builtinConverterFunc func(value string, opts castOptions) (any, error)
+
+// builtinConverters handles the standard library types used in most configs,
+// they take precedence over the primitive kinds and over encoding.TextUnmarshaler
+var builtinConverters = map[reflect.Type]builtinConverterFunc{
+	reflect.TypeFor[time.Duration](): func(value string, _ castOptions) (any, error) {
+		return time.ParseDuration(value)
+	},
+	// time.Time uses the env_layout tag, RFC3339 by default
+	reflect.TypeFor[time.Time](): func(value string, opts castOptions) (any, error) {
+		layout := opts.layout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		return time.Parse(layout, value)
+	},
+	reflect.TypeFor[*time.Location](): func(value string, _ castOptions) (any, error) {
+		return time.LoadLocation(value)
+	},
+	reflect.TypeFor[*url.URL](): func(value string, _ castOptions) (any, error) {
+		return url.Parse(value)
+	},
+	reflect.TypeFor[*regexp.Regexp](): func(value string, _ castOptions) (any, error) {
+		return regexp.Compile(value)
+	},
+	// file modes are written in octal, eg: 0644 or 0o755
+	reflect.TypeFor[os.FileMode](): func(value string, _ castOptions) (any, error) {
+		mode, err := strconv.ParseUint(strings.TrimPrefix(value, "0o"), 8, 32)
+		return os.FileMode(mode), err
+	},
+	reflect.TypeFor[netip.Prefix](): func(value string, _ castOptions) (any, error) {
+		return netip.ParsePrefix(value)
+	},
+}
+
+// RegisterConverter registers a parser for a type, it takes precedence over the built-in converters
+// and kinds and is used for fields, slice elements, map values and pointers of that type.
+// Converters are scoped to the manager they are registered on
+// example: manager.RegisterConverter(reflect.TypeFor[decimal.Decimal](), func(v string) (any, error) { return decimal.NewFromString(v) })
+func (e *EnvManager) RegisterConverter(typ reflect.Type, converter ConverterFunc) *EnvManager {
+	if e.converters == nil {
+		e.converters = make(map[reflect.Type]ConverterFunc)
+	}
+	e.converters[typ] = converter
+	return e
+}
+
+func castStringWithConverter(value string, target reflect.Type, opts castOptions, converter builtinConverterFunc) (reflect.Value, error) {
+	converted, err := converter(value, opts)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if converted == nil {
+		return reflect.Zero(target), nil
+	}
+	castValue := reflect.ValueOf(converted)
+	if !castValue.Type().AssignableTo(target) {
+		return reflect.Value{}, fmt.Errorf("converter returned %s instead of %s", castValue.Type(), target)
+	}
+	return castValue, nil
+}
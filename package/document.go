@@ -0,0 +1,309 @@
+package env_manager
+
+import (
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Kinds of the nodes of a Document
+const (
+	NODE_ENTRY     = iota + 1 // KEY=value, with an optional export prefix and trailing comment
+	NODE_COMMENT              // line holding only a comment
+	NODE_BLANK                // empty or white space only line
+	NODE_DIRECTIVE            // include directive or export only line, eg: #include ./common.env, export KEY
+)
+
+// Document is a lossless syntax tree of an env file, nodes that are not edited are written back as they were read.
+// Values are kept as written in the file: they are not substituted and escapes are not interpreted
+type Document struct {
+	Nodes []*Node
+}
+
+// Node is a line of a Document, or the lines of an entry with a multi line value
+type Node struct {
+	Kind   int
+	Key    string // key of entries, without the export prefix
+	Value  string // value of entries as written, without the quotes
+	Quote  rune   // quote of the value, 0 for unquoted values
+	Export bool   // the entry has an export prefix
+
+	raw      string // text of the node as read
+	lead     string // text before the key: indentation and export prefix
+	sep      string // text between the key and the value: '=' and white space
+	suffix   string // text after the value: white space, comment and line break
+	modified bool   // entries edited after parsing are written from their parts
+}
+
+// Text returns the node as it is written
+func (n *Node) Text() string {
+	if n.Kind != NODE_ENTRY || !n.modified {
+		return n.raw
+	}
+	value := n.Value
+	if n.Quote != 0 {
+		value = string(n.Quote) + value + string(n.Quote)
+	}
+	return n.lead + n.Key + n.sep + value + n.suffix
+}
+
+// ParseDocument parses an env file into a Document, include directives are kept without being followed
+func ParseDocument(file string) (*Document, error) {
+	content, err := readFile(nil, file)
+	if err != nil {
+		return nil, err
+	}
+	return parseDocument(file, content)
+}
+
+func parseDocument(file, content string) (*Document, error) {
+	// the whole content is kept to write the untouched nodes back
+	p := newReaderParser(file, strings.NewReader(content), nil, nil)
+	p.noInclude = true
+	if err := p.parseEntries(); err != nil {
+		return nil, err
+	}
+
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	offsets := make([]int, len(lines)+1)
+	for i, line := range lines {
+		offsets[i+1] = offsets[i] + len(line)
+	}
+
+	doc := &Document{}
+	next := 0 // first line not added to the document
+	for _, entry := range p.entries {
+		first := entry.Line - 1
+		for ; next < first; next++ {
+			doc.Nodes = append(doc.Nodes, newLineNode(lines[next]))
+		}
+		node, last := newEntryNode(content, offsets, entry)
+		doc.Nodes = append(doc.Nodes, node)
+		next = last + 1
+	}
+	for ; next < len(lines); next++ {
+		doc.Nodes = append(doc.Nodes, newLineNode(lines[next]))
+	}
+	return doc, nil
+}
+
+// newLineNode returns the node of a line without entries
+func newLineNode(line string) *Node {
+	trimmed := strings.TrimSpace(line)
+	switch _, isInclude := parseIncludeDirective(line); {
+	case trimmed == "":
+		return &Node{Kind: NODE_BLANK, raw: line}
+	case isInclude, stripExport(trimmed) != trimmed:
+		return &Node{Kind: NODE_DIRECTIVE, raw: line}
+	default:
+		return &Node{Kind: NODE_COMMENT, raw: line}
+	}
+}
+
+// newEntryNode splits the text of an entry into its parts and returns the node with the index of its last line
+func newEntryNode(content string, offsets []int, entry *envEntry) (*Node, int) {
+	first := entry.Line - 1
+	start := offsets[first]
+	lineText := content[start:offsets[first+1]]
+
+	node := &Node{Kind: NODE_ENTRY, Key: entry.key}
+	keyStart := len(lineText) - len(strings.TrimLeftFunc(lineText, unicode.IsSpace))
+	if rest, found := strings.CutPrefix(lineText[keyStart:], "export"); found && rest != "" && unicode.IsSpace(rune(rest[0])) {
+		// a key named export is not a prefix
+		if afterExport := strings.TrimLeftFunc(rest, unicode.IsSpace); strings.HasPrefix(afterExport, entry.key) {
+			node.Export = true
+			keyStart = len(lineText) - len(afterExport)
+		}
+	}
+	keyEnd := keyStart + len(entry.key)
+
+	valueStart := start + keyEnd
+	if entry.Column > 0 {
+		valueStart = start + entry.Column - 1
+	}
+	valueEnd := findValueEnd(content, valueStart)
+	last := first
+	if valueEnd > valueStart {
+		last = sort.SearchInts(offsets, valueEnd) - 1
+	}
+
+	node.raw = content[start:offsets[last+1]]
+	node.lead = lineText[:keyStart]
+	node.sep = content[start+keyEnd : valueStart]
+	node.suffix = content[valueEnd:offsets[last+1]]
+	value := content[valueStart:valueEnd]
+	if q := entry.Quote; len(value) >= 2 && strings.ContainsRune("'\"`", q) && rune(value[0]) == q && rune(value[len(value)-1]) == q {
+		node.Value, node.Quote = value[1:len(value)-1], q
+	} else {
+		node.Value = value
+	}
+	return node, last
+}
+
+// returns the end of the value starting at start: after its closing quote or
+// after its last character before a comment or the line end
+func findValueEnd(content string, start int) int {
+	end := start
+	quote := byte(0)
+	for i := start; i < len(content); i++ {
+		ch := content[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' && quote == '"' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+			end = min(i+1, len(content))
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+			end = i + 1
+		case ch == '#' || ch == '\n':
+			return end
+		case !unicode.IsSpace(rune(ch)):
+			end = i + 1
+		}
+	}
+	return end
+}
+
+// Get returns the last declaration of key, the one that wins when the file is parsed
+func (d *Document) Get(key string) (*Node, bool) {
+	for i := len(d.Nodes) - 1; i >= 0; i-- {
+		if node := d.Nodes[i]; node.Kind == NODE_ENTRY && node.Key == key {
+			return node, true
+		}
+	}
+	return nil, false
+}
+
+// Set updates the value of the last declaration of key, or appends a new entry when the key is missing.
+// The value is written as is, so ${VAR} references are kept. The quote style of the entry is kept
+// when it can hold the value, otherwise the first style that can is used: unquoted, double, single, backtick
+func (d *Document) Set(key, value string) error {
+	node, found := d.Get(key)
+	if !found {
+		if !isVarName(key) {
+			return newDocumentEditErr(key, "invalid key")
+		}
+		node = &Node{Kind: NODE_ENTRY, Key: key, sep: "=", suffix: "\n"}
+	}
+	quote, ok := quoteFor(value, node.Quote, found)
+	if !ok {
+		return newDocumentEditErr(key, "the value cannot be written in any quote style")
+	}
+
+	if !found {
+		if n := len(d.Nodes); n > 0 && !strings.HasSuffix(d.Nodes[n-1].Text(), "\n") {
+			d.Nodes[n-1].raw += "\n"
+			d.Nodes[n-1].suffix += "\n"
+		}
+		d.Nodes = append(d.Nodes, node)
+	}
+	if !strings.Contains(node.sep, "=") {
+		// lines with only a key get a separator
+		node.sep += "="
+	}
+	node.Value, node.Quote, node.modified = value, quote, true
+	return nil
+}
+
+// Delete removes every declaration of key and reports if there was one
+func (d *Document) Delete(key string) bool {
+	count := len(d.Nodes)
+	d.Nodes = slices.DeleteFunc(d.Nodes, func(node *Node) bool {
+		return node.Kind == NODE_ENTRY && node.Key == key
+	})
+	return len(d.Nodes) != count
+}
+
+// Rename renames every declaration of oldKey, newKey must not be declared already
+func (d *Document) Rename(oldKey, newKey string) error {
+	if !isVarName(newKey) {
+		return newDocumentEditErr(newKey, "invalid key")
+	}
+	if _, found := d.Get(newKey); found {
+		return newDocumentEditErr(newKey, "the key is already declared")
+	}
+	if _, found := d.Get(oldKey); !found {
+		return newKeyNotFoundErr(oldKey)
+	}
+	for _, node := range d.Nodes {
+		if node.Kind == NODE_ENTRY && node.Key == oldKey {
+			node.Key, node.modified = newKey, true
+		}
+	}
+	return nil
+}
+
+// Write writes the document to w, a document without edits is written exactly as it was read
+func (d *Document) Write(w io.Writer) error {
+	for _, node := range d.Nodes {
+		if _, err := io.WriteString(w, node.Text()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// returns the quote used to write value verbatim, preferring current for existing entries
+func quoteFor(value string, current rune, keepCurrent bool) (rune, bool) {
+	// values are trimmed when parsed, whatever their quotes
+	if value != strings.TrimSpace(value) {
+		return 0, false
+	}
+	candidates := []rune{0, '"', '\'', '`'}
+	if keepCurrent {
+		candidates = append([]rune{current}, candidates...)
+	}
+	for _, quote := range candidates {
+		if canQuote(value, quote) {
+			return quote, true
+		}
+	}
+	return 0, false
+}
+
+// reports if value is read back unchanged when written with quote
+func canQuote(value string, quote rune) bool {
+	switch quote {
+	case 0:
+		return !strings.ContainsAny(value, "'\"`#\n")
+	case '"':
+		for i := 0; i < len(value); i++ {
+			if value[i] == '"' {
+				return false
+			}
+			if value[i] == '\\' {
+				if i+1 == len(value) {
+					return false
+				}
+				if _, ok := escapeSequences[rune(value[i+1])]; !ok {
+					return false
+				}
+				i++
+			}
+		}
+		return true
+	default:
+		return !strings.ContainsRune(value, quote)
+	}
+}
+
+// reports if str is a valid variable name
+func isVarName(str string) bool {
+	if !isVarNameStart(str, 0) {
+		return false
+	}
+	for i := 1; i < len(str); i++ {
+		if !isVarNameChar(str[i]) {
+			return false
+		}
+	}
+	return true
+}
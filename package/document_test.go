@@ -0,0 +1,100 @@
+package env_manager
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDocumentRoundTrip(t *testing.T) {
+	for _, file := range []string{"../test_data/simple.env", "../test_data/complex.env", "../test_data/big.env"} {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err := ParseDocument(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out strings.Builder
+		if err := doc.Write(&out); err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, out.String(), string(content), "Documents without edits must be written as they were read")
+	}
+}
+
+func TestDocumentNodes(t *testing.T) {
+	doc, err := ParseDocument(newTestEnvFile(t, `# app settings
+
+export VERSION = "1.2.3" # bumped by CI
+#include ./common.env
+export NAME
+KEY
+MULTI='first
+second'`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := []int{}
+	for _, node := range doc.Nodes {
+		kinds = append(kinds, node.Kind)
+	}
+	assertCondition(t, slices.Equal(kinds, []int{NODE_COMMENT, NODE_BLANK, NODE_ENTRY, NODE_DIRECTIVE, NODE_DIRECTIVE, NODE_ENTRY, NODE_ENTRY}),
+		fmt.Sprintf("Unexpected node kinds %v", kinds))
+
+	version, _ := doc.Get("VERSION")
+	assertEqual(t, version.Value, "1.2.3", "Values must be read without quotes")
+	assertEqual(t, version.Quote, '"', "Quote style must be kept")
+	assertCondition(t, version.Export, "Export prefix must be kept")
+	multi, _ := doc.Get("MULTI")
+	assertEqual(t, multi.Value, "first\nsecond", "Multi line values must be a single node")
+}
+
+func TestDocumentEdits(t *testing.T) {
+	doc, err := ParseDocument(newTestEnvFile(t, `# app settings
+export VERSION = "1.2.3" # bumped by CI
+API_KEY=old-key
+  TOKEN='abc'   # rotated monthly
+DEBUG=true
+DEBUG=false
+KEY`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, kv := range [][2]string{{"VERSION", "1.2.4"}, {"API_KEY", "new key #2"}, {"TOKEN", "it's"}, {"KEY", "value"}, {"NEW", "${VERSION}"}} {
+		if err := doc.Set(kv[0], kv[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertCondition(t, doc.Delete("DEBUG"), "Delete must report removed keys")
+	assertCondition(t, !doc.Delete("DEBUG"), "Delete must report missing keys")
+	if err := doc.Rename("API_KEY", "SECRET_KEY"); err != nil {
+		t.Fatal(err)
+	}
+	assertCondition(t, doc.Rename("TOKEN", "VERSION") != nil, "Rename must not create duplicate keys")
+	assertCondition(t, doc.Set("TOKEN", " padded ") != nil, "Values that cannot be read back must be rejected")
+
+	var out strings.Builder
+	if err := doc.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, out.String(), `# app settings
+export VERSION = "1.2.4" # bumped by CI
+SECRET_KEY="new key #2"
+  TOKEN="it's"   # rotated monthly
+KEY=value
+NEW=${VERSION}
+`, "Edits must keep the formatting of the file")
+
+	p := newTestParser(t, newTestEnvFile(t, out.String()))
+	if err := p.parse(); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, p.env["SECRET_KEY"], "new key #2", "Edited files must parse back to the new values")
+	assertEqual(t, p.env["NEW"], "1.2.4", "Values must be written as is")
+}
@@ -1,10 +1,15 @@
 package env_manager
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
+	"reflect"
+	"sync"
+	"time"
 )
 
 const (
@@ -13,22 +18,60 @@ const (
 	SILENT
 )
 
+// Binding modes, they decide where BindEnv reads the values from
+const (
+	BIND_OS_ONLY   = iota + 1 // only the process environment is used, LoadEnv has to be called before binding
+	BIND_FILE_WINS            // values parsed from the env files override the process environment
+	BIND_OS_WINS              // the process environment overrides the values parsed from the env files
+)
+
 // EnvManager is a struct that holds the file name and silent mode
 // It is used to manage environment variables from a file
 type EnvManager struct {
-	files   []string
-	envMap  map[string]string //contains all the
-	logger  *log.Logger
-	logMode int
+	files    []string
+	fsys     fs.FS             // file system of the env files, nil for the os one
+	envMap   map[string]string //contains all the
+	origins  map[string]Origin // where each key of envMap was declared
+	logger   *log.Logger
+	logMode  int
+	bindMode int
+	sources  []Source // explicit source chain, overrides the bind mode when set
+
+	converters map[reflect.Type]ConverterFunc // registered with RegisterConverter
+	formats    map[string]FormatFunc          // registered with RegisterFormat
+
+	mu            sync.Mutex // guards envMap while the files are parsed and bound
+	watchInterval time.Duration
+	commands      commandRunner // $(...) substitution, off until EnableCommandSubstitution is called
+
+	strict         bool       // keys declared twice in a file are parser errors
+	overridePolicy int        // how keys overriding the keys of an earlier file are handled
+	overrides      []Override // keys overridden during the last parsing
+
+	preLoad map[string]*string // process env values of the keys set by LoadEnv as they were before, nil when unset
 }
 
 func NewEnvManager(files ...string) (*EnvManager, error) {
+	return newEnvManager(nil, files)
+}
+
+// NewEnvManagerFS returns a manager reading its env files from fsys, eg: default env files embedded with
+// //go:embed or a fstest.MapFS in tests. The paths are slash separated paths of fsys, and so are the paths
+// of their include directives. LoadEnv still sets the variables in the process environment
+func NewEnvManagerFS(fsys fs.FS, files ...string) (*EnvManager, error) {
+	if fsys == nil {
+		return nil, newInvalidUsageErr("file system", "file system must not be nil")
+	}
+	return newEnvManager(fsys, files)
+}
+
+func newEnvManager(fsys fs.FS, files []string) (*EnvManager, error) {
 	// if no files are provided then .env is checked
 	if len(files) == 0 {
 		files = []string{".env"}
 	}
 	for _, file := range files {
-		if _, err := os.Stat(file); os.IsNotExist(err) {
+		if _, err := statFile(fsys, file); errors.Is(err, fs.ErrNotExist) {
 			return nil, newConfigError(fmt.Errorf("file %s does not exist", file))
 		}
 	}
@@ -37,10 +80,14 @@ func NewEnvManager(files ...string) (*EnvManager, error) {
 	l.SetFlags(0)
 
 	return &EnvManager{
-		envMap:  make(map[string]string),
-		files:   files,
-		logger:  l,
-		logMode: DEFAULT,
+		envMap:         make(map[string]string),
+		files:          files,
+		fsys:           fsys,
+		logger:         l,
+		logMode:        DEFAULT,
+		bindMode:       BIND_OS_ONLY,
+		overridePolicy: OVERRIDE_ALLOW,
+		watchInterval:  DEFAULT_WATCH_INTERVAL,
 	}, nil
 }
 
@@ -56,43 +103,158 @@ func (e *EnvManager) SetMode(mode int) *EnvManager {
 	return e
 }
 
+// SetBindMode sets where BindEnv looks up the values. With BIND_FILE_WINS and BIND_OS_WINS the
+// parsed env files are layered over the process environment, so structs can be bound without
+// calling LoadEnv and the process environment is never modified
+func (e *EnvManager) SetBindMode(mode int) *EnvManager {
+	switch mode {
+	case BIND_OS_ONLY, BIND_FILE_WINS, BIND_OS_WINS:
+		e.bindMode = mode
+	default:
+		e.bindMode = BIND_OS_ONLY
+	}
+	return e
+}
+
+// SetSources sets an explicit chain of sources used by BindEnv and the ${VAR} substitution,
+// the first source that has a key wins. The chain replaces the one derived from the bind mode
+// example: manager.SetSources(NewOSSource(), manager.FileSource(), NewDirSource("/run/secrets"))
+func (e *EnvManager) SetSources(sources ...Source) *EnvManager {
+	e.sources = sources
+	return e
+}
+
+// FileSource returns a source backed by the variables parsed from the manager's env files
+func (e *EnvManager) FileSource() Source {
+	return managerSource{e}
+}
+
 func (e *EnvManager) SetLogger(l *log.Logger) *EnvManager {
 	e.logger = l
 	return e
 }
 
 func (e *EnvManager) GetEnvMap() map[string]string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// parsing errors are logged by parseEnv, the previously parsed map is returned
 	e.parseEnv()
 	return e.envMap
 }
 
 // Loads the env variables from an env file
 // supports use of quotes, double quotes, backticks, and variable substituion
-func (e *EnvManager) LoadEnv() {
-	e.parseEnv()
+// The first parsing or loading error is returned as an *EnvError
+func (e *EnvManager) LoadEnv() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.parseEnv(); err != nil {
+		return err
+	}
+	e.snapshotPreLoad()
 	if err := loadEnvMap(e.envMap); err != nil {
 		e.Log(HIGH, "Error loading environment variables: %v", err)
+		return err
 	}
+	return nil
 }
 
 // Binds a pointer varaible to env varaibles. The assignment is done based on the value provided in
 // the field tag 'env'
 // example: cat struct{foo string `env:"FOO"`} gets its field foo binded to the varaible 'FOO' 's value
-func (e *EnvManager) BindEnv(envStructPtr any) {
+// Every missing key or invalid value is collected and returned together as EnvErrors
+func (e *EnvManager) BindEnv(envStructPtr any) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	e.Log(MED, "Binding environment variables")
-	if err := e.bindEnvWithPrefix(envStructPtr, ""); err != nil {
+	if e.usesEnvFiles() {
+		if err := e.parseEnv(); err != nil {
+			return err
+		}
+	}
+	if err := e.bindEnvWithPrefix(envStructPtr, "", structName(envStructPtr)); err != nil {
 		e.Log(HIGH, "Error binding environment variable: %v", err)
+		return err
 	}
+	return nil
 }
 
-func (e *EnvManager) parseEnv() {
+// parseEnv parses the env files into a new map, so parsing again never substitutes previous results,
+// the previous map is kept when parsing fails
+func (e *EnvManager) parseEnv() error {
+	oldEnvMap, oldOrigins := e.envMap, e.origins
+	e.envMap, e.origins = make(map[string]string), make(map[string]Origin)
+	overrides := []Override{}
 	for _, file := range e.files {
-		if parser, err := newEnvParser(file, e.envMap); err == nil {
-			if err := parser.parse(); err != nil {
-				e.Log(HIGH, "Error parsing env file %s: %v", file, err)
-			}
-		} else {
+		reader, err := openFile(e.fsys, file)
+		if err != nil {
 			e.Log(HIGH, "Error creating env parser for file %s: %v", file, err)
+			e.envMap, e.origins = oldEnvMap, oldOrigins
+			return err
+		}
+		parser := newReaderParser(file, reader, e.envMap, e.parseSource())
+		parser.fsys = e.fsys
+		parser.commands = e.commands
+		parser.origins = e.origins
+		parser.strict = e.strict
+		if err := parser.parse(); err != nil {
+			e.Log(HIGH, "Error parsing env file %s: %v", file, err)
+			e.envMap, e.origins = oldEnvMap, oldOrigins
+			return err
+		}
+		if err := e.checkOverrides(parser.overrides); err != nil {
+			e.Log(HIGH, "Error parsing env file %s: %v", file, err)
+			e.envMap, e.origins = oldEnvMap, oldOrigins
+			return err
 		}
+		overrides = append(overrides, parser.overrides...)
 	}
+	e.overrides = overrides
+	return nil
+}
+
+// source returns the chain of sources values are looked up from
+func (e *EnvManager) source() Source {
+	if e.sources != nil {
+		return sourceChain(e.sources)
+	}
+	switch e.bindMode {
+	case BIND_FILE_WINS:
+		return sourceChain{managerSource{e}, osSource{}}
+	case BIND_OS_WINS:
+		return sourceChain{osSource{}, managerSource{e}}
+	default:
+		return osSource{}
+	}
+}
+
+// parseSource returns the source of the ${VAR} references, the keys set by LoadEnv are read from
+// the process environment as they were before, so that PATH=${PATH}:/bin is not extended on every load
+func (e *EnvManager) parseSource() Source {
+	if len(e.preLoad) == 0 {
+		return e.source()
+	}
+	return withPreLoadEnv(e.source(), e.preLoad)
+}
+
+// snapshotPreLoad records the process env values of the keys about to be set for the first time
+func (e *EnvManager) snapshotPreLoad() {
+	if e.preLoad == nil {
+		e.preLoad = make(map[string]*string)
+	}
+	for key := range e.envMap {
+		if _, ok := e.preLoad[key]; ok {
+			continue
+		}
+		if value, ok := os.LookupEnv(key); ok {
+			e.preLoad[key] = &value
+		} else {
+			e.preLoad[key] = nil
+		}
+	}
+}
+
+// reports if binding may read the parsed env files directly
+func (e *EnvManager) usesEnvFiles() bool {
+	return e.sources != nil || e.bindMode != BIND_OS_ONLY
 }
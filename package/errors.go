@@ -1,18 +1,22 @@
 package env_manager
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 )
 
 type ErrType int
 
+// Error types start at 1 so the zero ErrType matches no error
 const (
-	KEY_NOT_FOUND_ERROR = iota
+	KEY_NOT_FOUND_ERROR = iota + 1
 	INVALID_USAGE_ERROR
 	TYPE_CAST_ERROR
 	PARSER_ERROR
 	CONFIG_ERROR
 	UNEXPECTED_ERROR
+	VALIDATION_ERROR
 )
 
 const (
@@ -22,6 +26,7 @@ const (
 	PARSER_ERROR_MSG     = "Invalid env file syntax"
 	CONFIG_ERROR_MSG     = "Configuration error"
 	UNEXPECTED_ERROR_MSG = "Unexpected error"
+	VALIDATION_ERROR_MSG = "Validation failed"
 )
 
 func (err *ErrType) toString() string {
@@ -32,20 +37,85 @@ func (err *ErrType) toString() string {
 		return INVALID_USAGE_MSG
 	case TYPE_CAST_ERROR:
 		return TYPE_CAST_ERROR_MSG
+	case PARSER_ERROR:
+		return PARSER_ERROR_MSG
 	case CONFIG_ERROR:
 		return CONFIG_ERROR_MSG
+	case VALIDATION_ERROR:
+		return VALIDATION_ERROR_MSG
 	default:
 		return UNEXPECTED_ERROR_MSG
 	}
 }
 
 type EnvError struct {
-	Type ErrType
-	Err  error
+	Type   ErrType
+	Field  string  // path of the struct field being bound, eg: Config.Email.Port
+	Origin *Origin // where the value was declared, nil when it does not come from an env file
+	Err    error
 }
 
 func (e *EnvError) Error() string {
-	return fmt.Sprintf("error occured: %s\n\t%v", e.Type.toString(), e.Err)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "error occured: %s", e.Type.toString())
+	if e.Field != "" {
+		fmt.Fprintf(&sb, " in field %s", e.Field)
+	}
+	if e.Origin != nil {
+		fmt.Fprintf(&sb, " at %s", e.Origin)
+	}
+	fmt.Fprintf(&sb, "\n\t%v", e.Err)
+	return sb.String()
+}
+
+func (e *EnvError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is an *EnvError of the same type, so callers can check for a kind of error,
+// a target without a type matches nothing
+// example: errors.Is(err, &EnvError{Type: KEY_NOT_FOUND_ERROR})
+func (e *EnvError) Is(target error) bool {
+	t, ok := target.(*EnvError)
+	return ok && t.Type == e.Type && (t.Field == "" || t.Field == e.Field)
+}
+
+// EnvErrors holds every error found while binding a struct, so that all the
+// problems in an env file can be reported at once
+type EnvErrors []*EnvError
+
+func (errs EnvErrors) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d error(s) occured while binding environment variables", len(errs))
+	for _, err := range errs {
+		sb.WriteString("\n")
+		sb.WriteString(err.Error())
+	}
+	return sb.String()
+}
+
+func (errs EnvErrors) Unwrap() []error {
+	unwrapped := make([]error, len(errs))
+	for i, err := range errs {
+		unwrapped[i] = err
+	}
+	return unwrapped
+}
+
+// add appends err to the list, nested EnvErrors are flattened and
+// errors without a field path are tagged with the given field
+func (errs EnvErrors) add(err error, field string) EnvErrors {
+	switch err := err.(type) {
+	case EnvErrors:
+		return append(errs, err...)
+	case *EnvError:
+		if err.Field == "" {
+			err.Field = field
+		}
+		return append(errs, err)
+	default:
+		return append(errs, &EnvError{Type: UNEXPECTED_ERROR, Field: field, Err: err})
+	}
 }
 
 func newEnvError(kind ErrType, err error) *EnvError {
@@ -85,12 +155,74 @@ func newTypeCastErr(value, castType string, err error) *EnvError {
 		fmt.Errorf("%s cannot be casted to type %s (%v)", value, castType, err))
 }
 
+func newFormatErr(key, format string, err error) *EnvError {
+	return newEnvError(
+		TYPE_CAST_ERROR,
+		fmt.Errorf("value of %s is not valid %s (%v)", key, format, err))
+}
+
 func newNoKeysForMapErr(field string) *EnvError {
 	return newInvalidUsageErr("empty key in env_keys tag", field)
 }
 
+func newValidationErr(field, rule string, err error) *EnvError {
+	return newEnvError(
+		VALIDATION_ERROR,
+		fmt.Errorf("field %s failed rule %s: %v", field, rule, err))
+}
+
+func newSubstitutionErr(origin Origin, reason string) *EnvError {
+	err := newEnvError(
+		CONFIG_ERROR,
+		fmt.Errorf("substitution failed in %s reason: %s", origin, reason))
+	err.Origin = &origin
+	return err
+}
+
+func newOverrideErr(override Override) *EnvError {
+	err := newEnvError(
+		CONFIG_ERROR,
+		fmt.Errorf("key %s is already declared at %s", override.Key, override.Old))
+	err.Origin = &override.New
+	return err
+}
+
+func newDocumentEditErr(key, reason string) *EnvError {
+	return newEnvError(
+		INVALID_USAGE_ERROR,
+		fmt.Errorf("cannot edit key %s: %s", key, reason))
+}
+
 func newParserError(file string, line, ch int, reason string) *EnvError {
 	return newEnvError(
 		PARSER_ERROR,
 		fmt.Errorf("invalid sytax in %s:%d:%d reason: %s", file, line, ch, reason))
 }
+
+// includeError adds the include directives leading to an included file to its error
+type includeError struct {
+	chain []includeStep
+	err   error
+}
+
+func (i *includeError) Error() string {
+	steps := make([]string, len(i.chain))
+	for j, step := range i.chain {
+		steps[j] = fmt.Sprintf("%s:%d", step.file, step.line)
+	}
+	return fmt.Sprintf("%v (included from %s)", i.err, strings.Join(steps, " -> "))
+}
+
+func (i *includeError) Unwrap() error {
+	return i.err
+}
+
+// newIncludeErr keeps the type of the error of the included file, and is a config error otherwise
+func newIncludeErr(chain []includeStep, err error) *EnvError {
+	errType := ErrType(CONFIG_ERROR)
+	var envErr *EnvError
+	if errors.As(err, &envErr) {
+		errType, err = envErr.Type, envErr.Err
+	}
+	return newEnvError(errType, &includeError{chain, err})
+}
@@ -0,0 +1,53 @@
+package env_manager
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats supported by the env_format tag without registration
+const (
+	FORMAT_JSON = "json"
+	FORMAT_YAML = "yaml" // struct fields are matched with the yaml tag, or the lowercased field name
+)
+
+// FormatFunc decodes a whole env value into v, it has the signature of json.Unmarshal
+// so most decoders can be registered directly, eg: manager.RegisterFormat("toml", toml.Unmarshal)
+type FormatFunc func(data []byte, v any) error
+
+var builtinFormats = map[string]FormatFunc{
+	FORMAT_JSON: json.Unmarshal,
+	FORMAT_YAML: yaml.Unmarshal,
+}
+
+// RegisterFormat registers a decoder for the env_format tag, it is scoped to the manager
+func (e *EnvManager) RegisterFormat(name string, decode FormatFunc) *EnvManager {
+	if e.formats == nil {
+		e.formats = make(map[string]FormatFunc)
+	}
+	e.formats[name] = decode
+	return e
+}
+
+func (e *EnvManager) getFormat(name string) (FormatFunc, bool) {
+	if decode, ok := e.formats[name]; ok {
+		return decode, true
+	}
+	decode, ok := builtinFormats[name]
+	return decode, ok
+}
+
+// decodeFormattedValue decodes a value written in a format like json into a new value of the field type
+func (e *EnvManager) decodeFormattedValue(field reflect.StructField, format, key, value string) (reflect.Value, error) {
+	decode, ok := e.getFormat(format)
+	if !ok {
+		return reflect.Value{}, newInvalidUsageErr(field.Name, "unknown format "+format+", formats other than json and yaml must be registered with RegisterFormat")
+	}
+	ptrValue := reflect.New(field.Type)
+	if err := decode([]byte(value), ptrValue.Interface()); err != nil {
+		return reflect.Value{}, newFormatErr(key, format, err)
+	}
+	return ptrValue.Elem(), nil
+}
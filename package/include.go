@@ -0,0 +1,107 @@
+package env_manager
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Directives including another env file on their own line, eg: #include ./common.env
+// The path is relative to the including file and its entries are added at the position of the directive
+const (
+	DIRECTIVE_INCLUDE = "#include"
+	DIRECTIVE_IMPORT  = "@import"
+)
+
+// includeStep is an include directive at line of file
+type includeStep struct {
+	file string
+	line int
+}
+
+// returns the path of an include directive, the path may be quoted
+func parseIncludeDirective(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	for _, directive := range []string{DIRECTIVE_INCLUDE, DIRECTIVE_IMPORT} {
+		rest, found := strings.CutPrefix(trimmed, directive)
+		if !found || (rest != "" && !unicode.IsSpace(rune(rest[0]))) {
+			continue
+		}
+		// '#include' alone is still a comment
+		if rest == "" && directive == DIRECTIVE_INCLUDE {
+			return "", false
+		}
+		path := strings.TrimSpace(rest)
+		if len(path) >= 2 && (path[0] == '"' || path[0] == '\'') && path[len(path)-1] == path[0] {
+			return path[1 : len(path)-1], true
+		}
+		// comments starting with the word include, eg: '#include this file is generated', are kept as comments
+		if directive == DIRECTIVE_INCLUDE && !looksLikeEnvPath(path) {
+			return "", false
+		}
+		return path, true
+	}
+	return "", false
+}
+
+// reports if an unquoted #include path is a file path: a single word ending in .env
+// or starting with ./, ../ or /
+func looksLikeEnvPath(path string) bool {
+	if path == "" || strings.IndexFunc(path, unicode.IsSpace) != -1 {
+		return false
+	}
+	return strings.HasSuffix(path, ".env") || strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") || strings.HasPrefix(path, "/")
+}
+
+// include parses the entries of the file of an include directive and adds them to this parser
+func (e *envParser) include(path string, line int) error {
+	path = e.resolveInclude(path)
+	chain := append(slices.Clone(e.includedFrom), includeStep{e.file, line})
+	for _, step := range chain {
+		if isSameFile(step.file, path) {
+			return newIncludeErr(chain, fmt.Errorf("circular include of %s", path))
+		}
+	}
+
+	reader, err := openFile(e.fsys, path)
+	if err != nil {
+		return newIncludeErr(chain, err)
+	}
+	child := &envParser{
+		file:         path,
+		reader:       reader,
+		fsys:         e.fsys,
+		keyIndex:     make(map[string][]int),
+		strict:       e.strict,
+		includedFrom: chain,
+	}
+	if err := child.parseEntries(); err != nil {
+		// errors of nested includes already carry their whole chain
+		var incErr *includeError
+		if errors.As(err, &incErr) {
+			return err
+		}
+		return newIncludeErr(chain, err)
+	}
+	// duplicates were checked by the child parser, a file included twice is not a duplicate of itself
+	for _, entry := range child.entries {
+		e.appendEntry(entry)
+	}
+	return nil
+}
+
+// returns the path of an included file relative to the including file,
+// paths of file systems are slash separated and never absolute
+func (e *envParser) resolveInclude(name string) string {
+	if e.fsys != nil {
+		return path.Join(path.Dir(e.file), name)
+	}
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(filepath.Dir(e.file), name)
+}
@@ -0,0 +1,44 @@
+package env_manager
+
+import "fmt"
+
+// Origin is where a value was declared in an env file
+type Origin struct {
+	File   string
+	Line   int
+	Column int  // column of the first character of the value, the opening quote for quoted values
+	Quote  rune // quote of the value, 0 for unquoted values
+}
+
+func (o Origin) String() string {
+	return fmt.Sprintf("%s:%d:%d", o.File, o.Line, o.Column)
+}
+
+// GetOrigin returns where the value of key was declared in the env files of the manager,
+// the files are parsed by LoadEnv, BindEnv (except in BIND_OS_ONLY mode) and GetEnvMap
+func (e *EnvManager) GetOrigin(key string) (Origin, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	origin, ok := e.origins[key]
+	return origin, ok
+}
+
+// originOf returns the origin of the value bound for key, nil when the value does not come from the env files
+func (e *EnvManager) originOf(key string) *Origin {
+	origin, ok := e.origins[key]
+	if !ok {
+		return nil
+	}
+	if value, found := e.source().Lookup(key); !found || value != e.envMap[key] {
+		return nil
+	}
+	return &origin
+}
+
+// withOrigin adds the origin of the value of key to err when it is a single *EnvError without one
+func (e *EnvManager) withOrigin(err error, key string) error {
+	if envErr, ok := err.(*EnvError); ok && envErr.Origin == nil {
+		envErr.Origin = e.originOf(key)
+	}
+	return err
+}
@@ -0,0 +1,62 @@
+package env_manager
+
+import "fmt"
+
+// Policies for keys of an env file overriding the keys of an earlier file
+const (
+	OVERRIDE_ALLOW  = iota + 1 // later files silently override earlier ones (default)
+	OVERRIDE_WARN              // overrides are logged
+	OVERRIDE_REJECT            // overrides are config errors
+)
+
+// Override is a key declared again, either in the same file or in a later one
+type Override struct {
+	Key string
+	Old Origin // declaration that was overridden
+	New Origin // declaration that won
+}
+
+func (o Override) String() string {
+	return fmt.Sprintf("%s declared at %s is overridden at %s", o.Key, o.Old, o.New)
+}
+
+// SetStrict makes keys declared twice in the same file a PARSER_ERROR
+func (e *EnvManager) SetStrict(strict bool) *EnvManager {
+	e.strict = strict
+	return e
+}
+
+// SetOverridePolicy sets how keys overriding the keys of an earlier file are handled,
+// included files are separate files for the policy
+func (e *EnvManager) SetOverridePolicy(policy int) *EnvManager {
+	switch policy {
+	case OVERRIDE_ALLOW, OVERRIDE_WARN, OVERRIDE_REJECT:
+		e.overridePolicy = policy
+	default:
+		e.overridePolicy = OVERRIDE_ALLOW
+	}
+	return e
+}
+
+// GetOverrides returns the keys overridden during the last parsing of the env files, in declaration order
+func (e *EnvManager) GetOverrides() []Override {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.overrides
+}
+
+// checkOverrides applies the override policy to the overrides across files
+func (e *EnvManager) checkOverrides(overrides []Override) error {
+	for _, override := range overrides {
+		if override.Old.File == override.New.File {
+			continue
+		}
+		switch e.overridePolicy {
+		case OVERRIDE_WARN:
+			e.Log(HIGH, "Warning: %v", override)
+		case OVERRIDE_REJECT:
+			return newOverrideErr(override)
+		}
+	}
+	return nil
+}
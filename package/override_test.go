@@ -0,0 +1,86 @@
+package env_manager
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOverrideReport(t *testing.T) {
+	base := newTestEnvFile(t, "HOST=localhost\nPORT=80\nPORT=8080")
+	local := newTestEnvFile(t, "HOST=example.com")
+	manager, err := NewEnvManager(base, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := manager.GetEnvMap()
+	overrides := manager.GetOverrides()
+	assertEqual(t, env["HOST"], "example.com", "Later files must override earlier ones by default")
+	assertEqual(t, len(overrides), 2, "Every override must be reported")
+	assertEqual(t, overrides[0], Override{"PORT", Origin{base, 2, 6, 0}, Origin{base, 3, 6, 0}}, "Overrides in a file must be reported")
+	assertEqual(t, overrides[1], Override{"HOST", Origin{base, 1, 6, 0}, Origin{local, 1, 6, 0}}, "Overrides across files must be reported")
+}
+
+func TestStrictDuplicates(t *testing.T) {
+	manager := newTestManager(t, newTestEnvFile(t, "PORT=80\nHOST=localhost\nexport PORT=8080")).SetStrict(true)
+	err := manager.LoadEnv()
+
+	var envErr *EnvError
+	if !errors.As(err, &envErr) {
+		t.Fatalf("Expected EnvError got %v", err)
+	}
+	assertEqual(t, envErr.Type, PARSER_ERROR, "Duplicates in a file must be parser errors in strict mode")
+	assertCondition(t, strings.Contains(err.Error(), ".env:3:13") && strings.Contains(err.Error(), ".env:1:6"),
+		"Error must point to both declarations, got "+err.Error())
+}
+
+func TestStrictDiamondInclude(t *testing.T) {
+	dir := newTestEnvDir(t, map[string]string{
+		"top.env": "#include a.env\n#include b.env\nNAME=top",
+		"a.env":   "#include c.env\nA=a",
+		"b.env":   "#include c.env\nB=b",
+		"c.env":   "SHARED=c",
+	})
+	manager := newTestManager(t, filepath.Join(dir, "top.env")).SetStrict(true)
+	if err := manager.LoadEnv(); err != nil {
+		t.Fatalf("A file included twice must not be a duplicate in strict mode, got %v", err)
+	}
+	assertEqual(t, manager.GetEnvMap()["SHARED"], "c", "Keys of a file included twice must be loaded")
+
+	dir = newTestEnvDir(t, map[string]string{
+		"top.env": "#include a.env",
+		"a.env":   "A=1\nA=2",
+	})
+	err := newTestManager(t, filepath.Join(dir, "top.env")).SetStrict(true).LoadEnv()
+	var envErr *EnvError
+	if !errors.As(err, &envErr) || envErr.Type != PARSER_ERROR {
+		t.Fatalf("Duplicates in an included file must still be parser errors, got %v", err)
+	}
+}
+
+func TestOverridePolicy(t *testing.T) {
+	base := newTestEnvFile(t, "HOST=localhost")
+	local := newTestEnvFile(t, "HOST=example.com")
+
+	var logs bytes.Buffer
+	manager, err := NewEnvManager(base, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager.SetLogger(log.New(&logs, "", 0)).SetStrict(true).SetOverridePolicy(OVERRIDE_WARN)
+	assertEqual(t, manager.GetEnvMap()["HOST"], "example.com", "Overrides must be allowed when warning")
+	assertCondition(t, strings.Contains(logs.String(), "HOST declared at "+base+":1:6 is overridden at "+local+":1:6"),
+		"Overrides must be logged when warning, got "+logs.String())
+
+	err = manager.SetOverridePolicy(OVERRIDE_REJECT).LoadEnv()
+	var envErr *EnvError
+	if !errors.As(err, &envErr) {
+		t.Fatalf("Expected EnvError got %v", err)
+	}
+	assertEqual(t, envErr.Type, CONFIG_ERROR, "Rejected overrides must be config errors")
+	assertEqual(t, *envErr.Origin, Origin{local, 1, 6, 0}, "Error must cite the overriding declaration")
+}
@@ -1,103 +1,189 @@
 package env_manager
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"io"
+	"io/fs"
 	"strings"
 	"unicode"
 )
 
 const (
 	MAX_SUB_DEPTH = 10
+	MAX_LINE_SIZE = 1 << 20 // longest line the parser reads, lines are read one at a time
 )
 
+// envEntry is a key/value pair declared in an env file
+type envEntry struct {
+	Origin      // where the value starts, included files add entries with their own file
+	key         string
+	raw         string // value as written in the file, before substitution
+	value       string
+	substituted bool
+}
+
 type envParser struct {
-	file    string
-	content string
-	env     map[string]string
-	visited map[string]bool
+	file      string
+	reader    io.Reader // content of the file, read line by line and closed once parsed when it is an io.Closer
+	fsys      fs.FS     // file system of the included files, nil for the os one
+	env       map[string]string
+	entries   []*envEntry       // entries of this file in declaration order
+	keyIndex  map[string][]int  // indexes of the entries declaring each key
+	visited   map[int]bool      // entries being substituted, used to detect circular references
+	fallback  Source            // used for substituting variables that are not in the env files
+	commands  commandRunner     // runs $(...) substitutions, disabled unless the manager enables it
+	origins   map[string]Origin // origin of the value of each key of env, optional
+	strict    bool              // keys declared twice in a file are parser errors
+	noInclude bool              // include directives are skipped, documents keep them as is
+	overrides []Override        // keys declared again, reported when origins are tracked
+
+	includedFrom []includeStep // include directives leading to this file, empty for the files of the manager
 }
 
-func newEnvParser(file string, env map[string]string) (*envParser, error) {
-	content, err := openFile(file)
+func newEnvParser(file string, env map[string]string, fallback Source) (*envParser, error) {
+	reader, err := openFile(nil, file)
 	if err != nil {
 		return nil, err
 	}
+	return newReaderParser(file, reader, env, fallback), nil
+}
+
+// newReaderParser returns a parser reading the content of file from reader
+func newReaderParser(file string, reader io.Reader, env map[string]string, fallback Source) *envParser {
 	p := &envParser{
-		file:    file,
-		content: content,
-		visited: make(map[string]bool),
+		file:     file,
+		reader:   reader,
+		keyIndex: make(map[string][]int),
+		visited:  make(map[int]bool),
+	}
+	if fallback == nil {
+		p.fallback = NewOSSource()
+	} else {
+		p.fallback = fallback
 	}
 	if env == nil {
 		p.env = make(map[string]string)
 	} else {
 		p.env = env
 	}
-	return p, nil
+	return p
 }
 
-func (e *envParser) setEnv(key, value string) {
-	e.env[strings.TrimSpace(key)] = strings.TrimSpace(value)
+// ParseReader parses env file content read line by line from r, eg: os.Stdin or bytes.NewReader(content).
+// Variables missing in the content are substituted from the process environment and include directives
+// are resolved from the working directory. r is not closed
+func ParseReader(r io.Reader) (map[string]string, error) {
+	// hides the Close method of r, the caller owns it
+	p := newReaderParser("<reader>", struct{ io.Reader }{r}, nil, nil)
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return p.env, nil
 }
 
-// TODO: create a better method to check if string contains substitution for the cached value
-func (e *envParser) subValues(str string, depth int) (string, error) {
-	if depth > MAX_SUB_DEPTH {
-		return "", fmt.Errorf("maximum substitution depth %d exceeded", MAX_SUB_DEPTH)
+// ParseFS parses the env file name of fsys, eg: an embed.FS. Include directives are resolved in fsys
+func ParseFS(fsys fs.FS, name string) (map[string]string, error) {
+	reader, err := openFile(fsys, name)
+	if err != nil {
+		return nil, err
 	}
-	start := 0
-	for {
-		open := strings.Index(str[start:], "${")
-		if open == -1 {
-			break
-		}
-		open += start + 2
-		close := strings.Index(str[open:], "}")
-		if close == -1 {
-			break
-		}
-		close += open
-		varName := str[open:close]
-
-		if e.visited[varName] {
-			return "", newConfigError(fmt.Errorf("circular reference detected for variable %s", varName))
-		}
+	p := newReaderParser(name, reader, nil, nil)
+	p.fsys = fsys
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return p.env, nil
+}
 
-		if cached, ok := e.getEnv(varName); ok && !strings.Contains(cached, "${") {
-			str = str[:open-2] + cached + str[close+1:]
-			start = open - 2 + len(cached)
-			continue
-		}
+func (e *envParser) setEnv(key, value string, origin Origin) error {
+	origin.File = e.file
+	return e.addEntry(&envEntry{
+		Origin: origin,
+		key:    stripExport(strings.TrimSpace(key)),
+		raw:    strings.TrimSpace(value),
+	})
+}
 
-		e.visited[varName] = true
-		val, ok := e.getEnv(varName)
-		if !ok {
-			return "", newConfigError(fmt.Errorf("variable %s not found", varName))
+func (e *envParser) addEntry(entry *envEntry) error {
+	if e.strict {
+		for _, i := range e.keyIndex[entry.key] {
+			if first := e.entries[i]; first.File == entry.File {
+				return newParserError(entry.File, entry.Line, entry.Column, fmt.Sprintf("Duplicate key %s, first declared at %s", entry.key, first.Origin))
+			}
 		}
+	}
+	e.appendEntry(entry)
+	return nil
+}
 
-		subVal, err := e.subValues(val, depth+1)
-		if err != nil {
-			return "", err
-		}
+// appendEntry adds an entry without the strict check
+func (e *envParser) appendEntry(entry *envEntry) {
+	e.keyIndex[entry.key] = append(e.keyIndex[entry.key], len(e.entries))
+	e.entries = append(e.entries, entry)
+}
 
-		e.setEnv(varName, subVal)
+// stripExport removes the 'export ' prefix of shell compatible env files
+func stripExport(key string) string {
+	if rest, found := strings.CutPrefix(key, "export"); found && rest != "" && unicode.IsSpace(rune(rest[0])) {
+		return strings.TrimSpace(rest)
+	}
+	return key
+}
 
-		str = str[:open-2] + subVal + str[close+1:]
-		delete(e.visited, varName)
+// Escape sequences interpreted inside double quotes, \$ keeps a literal '$' that is not substituted
+var escapeSequences = map[rune]string{
+	'n':  "\n",
+	't':  "\t",
+	'r':  "\r",
+	'"':  "\"",
+	'\\': "\\",
+	'$':  "$",
+}
 
-		start = open - 2 + len(subVal)
+func (e *envParser) parse() error {
+	if err := e.parseEntries(); err != nil {
+		return err
 	}
-	return str, nil
+	// substitute the values in declaration order, the entries they reference are substituted first
+	for i := range e.entries {
+		if _, err := e.substituteEntry(i, 0); err != nil {
+			return err
+		}
+	}
+	for _, entry := range e.entries {
+		e.env[entry.key] = entry.value
+		if e.origins != nil {
+			if old, ok := e.origins[entry.key]; ok {
+				e.overrides = append(e.overrides, Override{entry.key, old, entry.Origin})
+			}
+			e.origins[entry.key] = entry.Origin
+		}
+	}
+	return nil
 }
 
-func (e *envParser) parse() error {
+// parseEntries reads the entries of the file, the entries of included files are added at the position of their directive
+func (e *envParser) parseEntries() error {
+	if closer, ok := e.reader.(io.Closer); ok {
+		defer closer.Close()
+	}
 	var key, value strings.Builder
 	isWithinQuotes := false
 	isQuoteEnd := false
 	quoteRune := rune(-1)
 	isKey := true
 	isEnd := false
+	isEscaped := false
+	valueStart := Origin{} // position of the first character of the value, or the one after '=' for empty values
+	isValueStarted := false
 
-	for lineNum, line := range strings.SplitAfter(e.content, "\n") {
+	lines := bufio.NewScanner(e.reader)
+	lines.Buffer(nil, MAX_LINE_SIZE)
+	lines.Split(scanLines)
+	for lineNum := 0; lines.Scan(); lineNum++ {
+		line := lines.Text()
 
 		if !isWithinQuotes {
 			isWithinQuotes = false
@@ -106,14 +192,52 @@ func (e *envParser) parse() error {
 			value.Reset()
 			isKey = true
 			isEnd = false
+			valueStart = Origin{}
+			isValueStarted = false
+
+			if path, found := parseIncludeDirective(line); found {
+				if path == "" {
+					return newParserError(e.file, lineNum+1, 1, "Missing path after include directive")
+				}
+				if e.noInclude {
+					continue
+				}
+				if err := e.include(path, lineNum+1); err != nil {
+					return err
+				}
+				continue
+			}
 		}
 
 		for chNum, ch := range line {
+			// escapes are kept as is in the value and interpreted during substitution
+			if isEscaped {
+				if _, ok := escapeSequences[ch]; !ok {
+					return newParserError(e.file, lineNum+1, chNum+1, fmt.Sprintf("Invalid escape sequence \\%c", ch))
+				}
+				value.WriteRune(ch)
+				isEscaped = false
+				continue
+			}
+			if ch == '\\' && isWithinQuotes && quoteRune == '"' && !isKey {
+				isEscaped = true
+				value.WriteRune(ch)
+				continue
+			}
+
+			if !isKey && !isValueStarted && !unicode.IsSpace(ch) && (ch != '#' || isWithinQuotes) {
+				valueStart.Line, valueStart.Column = lineNum+1, chNum+1
+				isValueStarted = true
+			}
+
 			switch ch {
 			case '\'', '"', '`':
 				if !isWithinQuotes {
 					quoteRune = ch
 					isWithinQuotes = true
+					if !isKey {
+						valueStart.Quote = ch
+					}
 				} else if quoteRune == ch {
 					isWithinQuotes = false
 					isQuoteEnd = true
@@ -138,6 +262,10 @@ func (e *envParser) parse() error {
 				if !isWithinQuotes {
 					if key.String() != "" && isKey {
 						isKey = false
+						valueStart.Line, valueStart.Column = lineNum+1, chNum+2
+					} else if !isKey {
+						// shell compatible values can contain '=', eg: QUERY=a=1&b=2
+						value.WriteRune(ch)
 					} else {
 						return newParserError(e.file, lineNum+1, chNum+1, "Keys cannot be empty")
 					}
@@ -171,18 +299,31 @@ func (e *envParser) parse() error {
 				break
 			}
 		}
-		if !isWithinQuotes && key.String() != "" {
-			e.setEnv(key.String(), value.String())
+		// lines without '=' like 'export NAME' only mark shell variables for export and are skipped
+		isExportOnly := isKey && strings.HasPrefix(key.String(), "export") && stripExport(key.String()) != key.String()
+		if !isWithinQuotes && key.String() != "" && !isExportOnly {
+			if valueStart.Line == 0 {
+				// lines without '=' declare an empty value
+				valueStart.Line = lineNum + 1
+			}
+			if err := e.setEnv(key.String(), value.String(), valueStart); err != nil {
+				return err
+			}
 		}
 	}
-
-	// substitute all variable values
-	for k, v := range e.env {
-		if subValue, err := e.subValues(v, 0); err != nil {
-			return err
-		} else {
-			e.env[k] = subValue
-		}
+	if err := lines.Err(); err != nil {
+		return newConfigError(fmt.Errorf("error reading file %s: %v", e.file, err))
 	}
 	return nil
 }
+
+// scanLines splits the content in lines like bufio.ScanLines, keeping the line breaks that end values
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i+1], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,350 @@
+package env_manager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParsingEscapeSequences(t *testing.T) {
+	p := newTestParser(t, newTestEnvFile(t, `NAME=world
+DOUBLE="tab\there\nquote \" slash \\ ${NAME}"
+DOLLAR="price \${NAME} is \$5"
+SINGLE='tab\there \n'
+BACKTICK=`+"`raw\\n`"+`
+UNQUOTED=raw\n`))
+	if err := p.parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, p.env["DOUBLE"], "tab\there\nquote \" slash \\ world", "Escapes must be interpreted in double quotes")
+	assertEqual(t, p.env["DOLLAR"], "price ${NAME} is $5", "\\$ must suppress substitution")
+	assertEqual(t, p.env["SINGLE"], `tab\there \n`, "Single quoted values must stay literal")
+	assertEqual(t, p.env["BACKTICK"], `raw\n`, "Backtick quoted values must stay literal")
+	assertEqual(t, p.env["UNQUOTED"], `raw\n`, "Unquoted values must stay literal")
+}
+
+func TestParsingInvalidEscapeSequence(t *testing.T) {
+	p := newTestParser(t, newTestEnvFile(t, "VALID=1\nINVALID=\"bad \\q escape\""))
+	err := p.parse()
+
+	var envErr *EnvError
+	if !errors.As(err, &envErr) {
+		t.Fatalf("Expected EnvError got %v", err)
+	}
+	assertEqual(t, envErr.Type, PARSER_ERROR, "Invalid escapes must be parser errors")
+	assertCondition(t, strings.Contains(err.Error(), ".env:2:15"), "Parser error must point to the escape, got "+err.Error())
+}
+
+func TestParsingShellCompatibleFile(t *testing.T) {
+	p := newTestParser(t, newTestEnvFile(t, `export APP=shell-app
+export   PORT=8080
+export APP
+exported=kept
+QUERY=a=1&b=$PORT
+GREETING="hello $APP, price $5"
+LITERAL='$APP'
+URL=http://$APP:${PORT}/`))
+	if err := p.parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, len(p.env), 7, "Export only lines must be skipped")
+	assertEqual(t, p.env["APP"], "shell-app", "export prefix must be stripped")
+	assertEqual(t, p.env["PORT"], "8080", "export prefix with spaces must be stripped")
+	assertEqual(t, p.env["exported"], "kept", "Keys starting with export must be kept")
+	assertEqual(t, p.env["QUERY"], "a=1&b=8080", "Unquoted values can contain '='")
+	assertEqual(t, p.env["GREETING"], "hello shell-app, price $5", "Bare $VAR must be expanded in double quotes")
+	assertEqual(t, p.env["URL"], "http://shell-app:8080/", "Bare $VAR must be expanded in unquoted values")
+	assertEqual(t, p.env["LITERAL"], "$APP", "Bare $VAR must not be expanded in single quotes")
+}
+
+func TestParsingParameterExpansion(t *testing.T) {
+	p := newTestParser(t, newTestEnvFile(t, `SET=value
+EMPTY=
+DEFAULT=${UNSET_GO_ENV_MANAGER:-fallback}
+DEFAULT_EMPTY=${EMPTY:-fallback}
+DEFAULT_UNSET=${EMPTY-fallback}
+NESTED=${UNSET_GO_ENV_MANAGER:-${SET}-${UNSET_GO_ENV_MANAGER:-deep}}
+ALTERNATE=${SET:+alt}
+ALTERNATE_EMPTY=[${EMPTY:+alt}]
+ALTERNATE_UNSET=${EMPTY+alt}
+REQUIRED=${SET:?must be set}`))
+	if err := p.parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, p.env["DEFAULT"], "fallback", ":- must use the default for unset variables")
+	assertEqual(t, p.env["DEFAULT_EMPTY"], "fallback", ":- must use the default for empty variables")
+	assertEqual(t, p.env["DEFAULT_UNSET"], "", "- must keep empty variables")
+	assertEqual(t, p.env["NESTED"], "value-deep", "Nested expressions must be expanded")
+	assertEqual(t, p.env["ALTERNATE"], "alt", ":+ must use the alternate for set variables")
+	assertEqual(t, p.env["ALTERNATE_EMPTY"], "[]", ":+ must be empty for empty variables")
+	assertEqual(t, p.env["ALTERNATE_UNSET"], "alt", "+ must use the alternate for empty variables")
+	assertEqual(t, p.env["REQUIRED"], "value", ":? must keep set variables")
+}
+
+func TestParsingRequiredVariable(t *testing.T) {
+	p := newTestParser(t, newTestEnvFile(t, "A=1\nMESSAGE=\"first line\nsecond ${UNSET_GO_ENV_MANAGER:?is needed for the message}\""))
+	err := p.parse()
+
+	var envErr *EnvError
+	if !errors.As(err, &envErr) {
+		t.Fatalf("Expected EnvError got %v", err)
+	}
+	assertEqual(t, envErr.Type, CONFIG_ERROR, "Required variables must be config errors")
+	assertCondition(t, strings.Contains(err.Error(), ".env:3"), "Error must point to the line of the reference, got "+err.Error())
+	assertCondition(t, strings.Contains(err.Error(), "is needed for the message"), "Error must contain the message, got "+err.Error())
+}
+
+func TestParsingDeclarationOrder(t *testing.T) {
+	p := newTestParser(t, newTestEnvFile(t, `A=first
+B=${A}
+A=second
+C=$A
+D=${LATER}
+LATER=later
+SINGLE='${A}'
+BACKTICK=`+"`${A}`"))
+	if err := p.parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, p.env["A"], "second", "Last declaration must win")
+	assertEqual(t, p.env["B"], "first", "References must see the value declared above them")
+	assertEqual(t, p.env["C"], "second", "References must see the value declared above them")
+	assertEqual(t, p.env["D"], "later", "Forward references must be resolved")
+	assertEqual(t, p.env["SINGLE"], "${A}", "Single quoted values must not be substituted")
+	assertEqual(t, p.env["BACKTICK"], "${A}", "Backtick quoted values must not be substituted")
+}
+
+func TestParsingIsDeterministic(t *testing.T) {
+	t.Setenv("TEST_BASE_PATH", "/usr/bin")
+	base := newTestEnvFile(t, "ROOT=/srv\nDATA=${ROOT}/data")
+	override := newTestEnvFile(t, "TEST_BASE_PATH=${TEST_BASE_PATH}:${DATA}/bin\nLOGS=${DATA}/logs")
+	manager, err := NewEnvManager(base, override)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager.SetBindMode(BIND_FILE_WINS)
+
+	first := maps.Clone(manager.GetEnvMap())
+	for range 5 {
+		second := manager.GetEnvMap()
+		assertCondition(t, maps.Equal(first, second), fmt.Sprintf("Parsing twice must give the same result, got %v and %v", first, second))
+	}
+	assertEqual(t, first["TEST_BASE_PATH"], "/usr/bin:/srv/data/bin", "Self references must use the value before the file")
+	assertEqual(t, first["LOGS"], "/srv/data/logs", "References to earlier files must be resolved")
+}
+
+func TestLoadEnvIsDeterministic(t *testing.T) {
+	t.Setenv("GO_ENV_MANAGER_PROBE_PATH", "/bin")
+	// unset keys are restored by t.Setenv at the end of the test
+	t.Setenv("GO_ENV_MANAGER_PROBE_NEW", "")
+	os.Unsetenv("GO_ENV_MANAGER_PROBE_NEW")
+	file := newTestEnvFile(t, "GO_ENV_MANAGER_PROBE_PATH=${GO_ENV_MANAGER_PROBE_PATH}:/x\n"+
+		"GO_ENV_MANAGER_PROBE_NEW=${GO_ENV_MANAGER_PROBE_NEW:-new}-x")
+	manager := newTestManager(t, file)
+
+	for range 3 {
+		if err := manager.LoadEnv(); err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, os.Getenv("GO_ENV_MANAGER_PROBE_PATH"), "/bin:/x", "Loading again must not extend the loaded value")
+		assertEqual(t, os.Getenv("GO_ENV_MANAGER_PROBE_NEW"), "new-x", "Keys unset before the first load must stay unset for references")
+	}
+}
+
+func TestParsingIncludes(t *testing.T) {
+	dir := newTestEnvDir(t, map[string]string{
+		".env": `#include ./layers/base.env
+TEAM=core
+@import "layers/local.env"
+# a regular comment
+URL=${HOST}:${PORT}`,
+		"layers/base.env":   "HOST=localhost\nPORT=80\nTEAM=base\n#include team.env",
+		"layers/team.env":   "OWNER=${TEAM}",
+		"layers/local.env":  "PORT=8080",
+		"layers/unused.env": "UNUSED=1",
+	})
+	p := newTestParser(t, filepath.Join(dir, ".env"))
+	if err := p.parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, len(p.env), 5, "Included entries must be added")
+	assertEqual(t, p.env["TEAM"], "core", "Entries after a directive must override the included ones")
+	assertEqual(t, p.env["PORT"], "8080", "Included entries must override the entries above the directive")
+	assertEqual(t, p.env["OWNER"], "base", "Nested includes must be resolved relative to the including file")
+	assertEqual(t, p.env["URL"], "localhost:8080", "Included entries must be substituted")
+}
+
+func TestParsingIncludeComments(t *testing.T) {
+	content := "#include this file is generated, do not edit\n#include\n#include settings\nA=1"
+	manager := newTestManager(t, newTestEnvFile(t, content))
+	if err := manager.LoadEnv(); err != nil {
+		t.Fatalf("Comments starting with #include must not be directives, got %v", err)
+	}
+	assertEqual(t, manager.GetEnvMap()["A"], "1", "Entries after #include comments must be parsed")
+}
+
+func TestParsingIncludeErrors(t *testing.T) {
+	dir := newTestEnvDir(t, map[string]string{
+		".env":        "A=1\n#include a.env",
+		"a.env":       "#include b.env",
+		"b.env":       "@import ./a.env",
+		"bad.env":     "#include broken.env",
+		"broken.env":  "OK=1\n=1",
+		"missing.env": "@import nowhere.env",
+	})
+
+	err := newTestParser(t, filepath.Join(dir, ".env")).parse()
+	var envErr *EnvError
+	if !errors.As(err, &envErr) {
+		t.Fatalf("Expected EnvError got %v", err)
+	}
+	assertEqual(t, envErr.Type, CONFIG_ERROR, "Circular includes must be config errors")
+	assertCondition(t, strings.Contains(err.Error(), "circular include") &&
+		strings.Contains(err.Error(), ".env:2 -> "+filepath.Join(dir, "a.env")+":1 -> "+filepath.Join(dir, "b.env")+":1"),
+		"Error must show the include chain, got "+err.Error())
+
+	err = newTestParser(t, filepath.Join(dir, "bad.env")).parse()
+	assertCondition(t, errors.As(err, &envErr) && envErr.Type == PARSER_ERROR, "Syntax errors of included files must be parser errors")
+	assertCondition(t, strings.Contains(err.Error(), "broken.env:2:1") && strings.Contains(err.Error(), "bad.env:1"),
+		"Error must point to the included file and its directive, got "+err.Error())
+
+	err = newTestParser(t, filepath.Join(dir, "missing.env")).parse()
+	assertCondition(t, err != nil && strings.Contains(err.Error(), "nowhere.env"), "Missing included files must be reported")
+}
+
+func TestParsingOrigins(t *testing.T) {
+	dir := newTestEnvDir(t, map[string]string{
+		".env": `A=1
+  export B =  'two'   # comment
+C="multi
+line"
+EMPTY=
+#include common.env`,
+		"common.env": "\nD=`four`",
+	})
+	p := newTestParser(t, filepath.Join(dir, ".env"))
+	p.origins = make(map[string]Origin)
+	if err := p.parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, common := filepath.Join(dir, ".env"), filepath.Join(dir, "common.env")
+	assertEqual(t, p.origins["A"], Origin{file, 1, 3, 0}, "Origin of unquoted values")
+	assertEqual(t, p.origins["B"], Origin{file, 2, 15, '\''}, "Origin must point to the opening quote")
+	assertEqual(t, p.origins["C"], Origin{file, 3, 3, '"'}, "Origin of multi line values must point to their first line")
+	assertEqual(t, p.origins["EMPTY"], Origin{file, 5, 7, 0}, "Origin of empty values must point after '='")
+	assertEqual(t, p.origins["D"], Origin{common, 2, 3, '`'}, "Origin of included values must point to the included file")
+}
+
+func TestParsingSubstitutionErrorOrigin(t *testing.T) {
+	p := newTestParser(t, newTestEnvFile(t, "A=1\nMESSAGE=\"first line\n  second ${UNSET_GO_ENV_MANAGER:-${ALSO_UNSET_GO_ENV_MANAGER}}\""))
+	err := p.parse()
+
+	var envErr *EnvError
+	if !errors.As(err, &envErr) || envErr.Origin == nil {
+		t.Fatalf("Expected EnvError with an origin got %v", err)
+	}
+	assertEqual(t, envErr.Origin.Line, 3, "Origin must point to the line of the reference")
+	assertEqual(t, envErr.Origin.Column, 34, "Origin must point to the column of the nested reference")
+	assertEqual(t, envErr.Origin.Quote, '"', "Origin must keep the quote of the value")
+}
+
+// closeTracker records if the parser closed a reader it does not own
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestParseReader(t *testing.T) {
+	reader := &closeTracker{Reader: strings.NewReader("NAME=reader\nGREETING=\"hello\n${NAME}\"\r\nLAST=no-newline")}
+	env, err := ParseReader(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, env["GREETING"], "hello\nreader", "Multi line values must be read across lines")
+	assertEqual(t, env["LAST"], "no-newline", "The last line must be read without a line break")
+	assertCondition(t, !reader.closed, "Readers of the caller must not be closed")
+
+	_, err = ParseReader(bytes.NewReader([]byte("LONG=" + strings.Repeat("x", MAX_LINE_SIZE))))
+	var envErr *EnvError
+	assertCondition(t, errors.As(err, &envErr) && envErr.Type == CONFIG_ERROR, fmt.Sprintf("Lines longer than MAX_LINE_SIZE must be config errors, got %v", err))
+}
+
+func TestParseFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config/.env":            {Data: []byte("#include ./layers/base.env\nURL=${HOST}:${PORT}")},
+		"config/layers/base.env": {Data: []byte("HOST=embedded\nPORT=80")},
+	}
+	env, err := ParseFS(fsys, "config/.env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, env["URL"], "embedded:80", "Included files must be read from the file system")
+
+	_, err = ParseFS(fsys, "missing.env")
+	assertCondition(t, err != nil, "Missing files must be reported")
+}
+
+func BenchmarkParseFile(b *testing.B) {
+	b.ReportAllocs()
+	for range b.N {
+		p, err := newEnvParser("../test_data/big.env", nil, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := p.parse(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseReader(b *testing.B) {
+	content, err := os.ReadFile("../test_data/big.env")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(content)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if _, err := ParseReader(bytes.NewReader(content)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestParsingLiteralDollarSigns(t *testing.T) {
+	p := newTestParser(t, newTestEnvFile(t, `NAME=app
+HASH=$2a$10$abcdefGHIJ
+PASSWORD="pa$word$"
+MIXED="$NAME:$UNSET_GO_ENV_MANAGER"`))
+	if err := p.parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, p.env["HASH"], "$2a$10$abcdefGHIJ", "Unknown bare references must be kept as is")
+	assertEqual(t, p.env["PASSWORD"], "pa$word$", "Unknown bare references must be kept in double quotes")
+	assertEqual(t, p.env["MIXED"], "app:$UNSET_GO_ENV_MANAGER", "Known bare references must still be expanded")
+
+	err := newTestParser(t, newTestEnvFile(t, "URL=${UNSET_GO_ENV_MANAGER}")).parse()
+	var envErr *EnvError
+	assertCondition(t, errors.As(err, &envErr) && envErr.Type == CONFIG_ERROR, "Unknown ${VAR} references must still be errors")
+}
@@ -0,0 +1,193 @@
+package env_manager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Source provides env variables to the binder and to the ${VAR} substitution of the parser
+// Custom backends (secret stores, remote configs...) can be used by implementing this interface
+type Source interface {
+	// Lookup returns the value of key and whether it exists in the source
+	Lookup(key string) (string, bool)
+	// Keys returns all the keys available in the source, used for wildcard matching
+	Keys() []string
+}
+
+// NewOSSource returns a source backed by the process environment
+func NewOSSource() Source {
+	return osSource{}
+}
+
+// NewMapSource returns a source backed by an in-memory map
+func NewMapSource(env map[string]string) Source {
+	return mapSource(env)
+}
+
+// NewFileSource parses the given env files and returns their variables as a source,
+// later files override the keys of earlier ones just like in NewEnvManager
+func NewFileSource(files ...string) (Source, error) {
+	env := make(map[string]string)
+	for _, file := range files {
+		parser, err := newEnvParser(file, env, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := parser.parse(); err != nil {
+			return nil, err
+		}
+	}
+	return mapSource(env), nil
+}
+
+// NewDirSource returns a source backed by a directory where every file is a variable,
+// the file name is the key and its content is the value (eg: docker and kubernetes secrets)
+// The files are read on every lookup so rotated values are picked up
+func NewDirSource(dir string) Source {
+	return dirSource(dir)
+}
+
+// NewSourceChain combines sources with explicit precedence, the first source that has a key wins
+func NewSourceChain(sources ...Source) Source {
+	return sourceChain(sources)
+}
+
+type osSource struct{}
+
+func (osSource) Lookup(key string) (string, bool) {
+	return os.LookupEnv(key)
+}
+
+func (osSource) Keys() []string {
+	environ := os.Environ()
+	keys := make([]string, 0, len(environ))
+	for _, kv := range environ {
+		if key, _, ok := strings.Cut(kv, "="); ok && key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+type mapSource map[string]string
+
+func (m mapSource) Lookup(key string) (string, bool) {
+	value, ok := m[key]
+	return value, ok
+}
+
+func (m mapSource) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// managerSource reads the env map of the manager on every lookup, so it stays valid when the files are parsed again
+type managerSource struct {
+	manager *EnvManager
+}
+
+func (m managerSource) Lookup(key string) (string, bool) {
+	return mapSource(m.manager.envMap).Lookup(key)
+}
+
+func (m managerSource) Keys() []string {
+	return mapSource(m.manager.envMap).Keys()
+}
+
+// preLoadSource is the process environment as it was before LoadEnv set the keys of before
+type preLoadSource struct {
+	before map[string]*string
+}
+
+func (p preLoadSource) Lookup(key string) (string, bool) {
+	if value, ok := p.before[key]; ok {
+		if value == nil {
+			return "", false
+		}
+		return *value, true
+	}
+	return os.LookupEnv(key)
+}
+
+func (p preLoadSource) Keys() []string {
+	keys := []string{}
+	for _, key := range (osSource{}).Keys() {
+		if _, ok := p.Lookup(key); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// withPreLoadEnv replaces the process environment in source by its values from before LoadEnv
+func withPreLoadEnv(source Source, before map[string]*string) Source {
+	switch s := source.(type) {
+	case osSource:
+		return preLoadSource{before}
+	case sourceChain:
+		chain := make(sourceChain, len(s))
+		for i, source := range s {
+			chain[i] = withPreLoadEnv(source, before)
+		}
+		return chain
+	default:
+		return source
+	}
+}
+
+type dirSource string
+
+func (d dirSource) Lookup(key string) (string, bool) {
+	// keys are plain file names, anything that could escape the directory is rejected
+	if key == "" || strings.ContainsAny(key, `/\`) || key == "." || key == ".." {
+		return "", false
+	}
+	content, err := os.ReadFile(filepath.Join(string(d), key))
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimRight(string(content), "\r\n"), true
+}
+
+func (d dirSource) Keys() []string {
+	entries, err := os.ReadDir(string(d))
+	if err != nil {
+		return nil
+	}
+	keys := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			keys = append(keys, entry.Name())
+		}
+	}
+	return keys
+}
+
+type sourceChain []Source
+
+func (c sourceChain) Lookup(key string) (string, bool) {
+	for _, source := range c {
+		if value, ok := source.Lookup(key); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+func (c sourceChain) Keys() []string {
+	seen := make(map[string]bool)
+	keys := []string{}
+	for _, source := range c {
+		for _, key := range source.Keys() {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
@@ -0,0 +1,236 @@
+package env_manager
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Operators of shell style parameter expansion, eg: ${NAME:-default}
+// the ':' variants also treat empty values as unset
+const (
+	EXPAND_DEFAULT         = ":-" // default value when unset or empty
+	EXPAND_DEFAULT_UNSET   = "-"  // default value when unset
+	EXPAND_REQUIRED        = ":?" // error when unset or empty
+	EXPAND_REQUIRED_UNSET  = "?"  // error when unset
+	EXPAND_ALTERNATE       = ":+" // alternate value when set and not empty
+	EXPAND_ALTERNATE_UNSET = "+"  // alternate value when set
+)
+
+// subContext is the entry being substituted and the position of the text being expanded
+type subContext struct {
+	index  int    // index of the entry being substituted
+	origin Origin // position of the expanded text, its quote is the quote of the entry
+	depth  int
+}
+
+// after returns the context of the text following prefix
+func (c subContext) after(prefix string) subContext {
+	if nl := strings.LastIndexByte(prefix, '\n'); nl != -1 {
+		c.origin.Line += strings.Count(prefix, "\n")
+		c.origin.Column = len(prefix) - nl
+	} else {
+		c.origin.Column += len(prefix)
+	}
+	return c
+}
+
+// substituteEntry expands the raw value of the entry at index i, single quoted and backtick values are literal
+func (e *envParser) substituteEntry(i, depth int) (string, error) {
+	entry := e.entries[i]
+	if entry.substituted {
+		return entry.value, nil
+	}
+	if depth > MAX_SUB_DEPTH {
+		return "", fmt.Errorf("maximum substitution depth %d exceeded", MAX_SUB_DEPTH)
+	}
+	if e.visited[i] {
+		return "", newConfigError(fmt.Errorf("circular reference detected for variable %s", entry.key))
+	}
+	e.visited[i] = true
+	defer delete(e.visited, i)
+
+	value := entry.raw
+	if entry.Quote != '\'' && entry.Quote != '`' {
+		ctx := subContext{index: i, origin: entry.Origin, depth: depth}
+		if entry.Quote != 0 {
+			// the raw value starts after the opening quote
+			ctx.origin.Column++
+		}
+		var err error
+		value, err = e.subValues(entry.raw, ctx)
+		if err != nil {
+			return "", err
+		}
+	}
+	entry.value, entry.substituted = value, true
+	return value, nil
+}
+
+// subValues replaces the ${VAR} references of a raw value, and its escape sequences when it is double quoted.
+// Bare $VAR references and $(...) commands, when enabled, are replaced in unquoted and double quoted values,
+// bare references to unknown variables are kept as is.
+func (e *envParser) subValues(str string, ctx subContext) (string, error) {
+	quote := ctx.origin.Quote
+	var result strings.Builder
+	for i := 0; i < len(str); i++ {
+		switch {
+		case str[i] == '\\' && quote == '"' && i+1 < len(str):
+			// escapes are validated while parsing
+			i++
+			result.WriteString(escapeSequences[rune(str[i])])
+		case strings.HasPrefix(str[i:], "${"):
+			end := findClosingBrace(str, i+2, quote)
+			if end == -1 {
+				result.WriteString(str[i:])
+				return result.String(), nil
+			}
+			value, err := e.expandParameter(str[i+2:end], ctx.after(str[:i]))
+			if err != nil {
+				return "", err
+			}
+			result.WriteString(value)
+			i = end
+		case strings.HasPrefix(str[i:], "$(") && (quote == 0 || quote == '"') && e.commands.enabled():
+			end := findClosingParen(str, i+2)
+			cmdOrigin := ctx.after(str[:i]).origin
+			if end == -1 {
+				return "", newSubstitutionErr(cmdOrigin, "unterminated command substitution")
+			}
+			file := ctx.origin.File
+			if e.fsys != nil {
+				// files of a fs.FS are usually not on disk, their commands run in the process working directory
+				file = ""
+			}
+			output, err := e.commands.run(str[i+2:end], file)
+			if err != nil {
+				return "", newSubstitutionErr(cmdOrigin, fmt.Sprintf("command %q failed: %v", str[i+2:end], err))
+			}
+			result.WriteString(output)
+			i = end
+		case str[i] == '$' && (quote == 0 || quote == '"') && isVarNameStart(str, i+1):
+			end := i + 1
+			for end < len(str) && isVarNameChar(str[end]) {
+				end++
+			}
+			value, found, err := e.lookupVar(str[i+1:end], ctx)
+			if err != nil {
+				return "", err
+			}
+			if !found {
+				// unknown bare references are kept as is, so values like passwords can contain '$'
+				value = str[i:end]
+			}
+			result.WriteString(value)
+			i = end - 1
+		default:
+			result.WriteByte(str[i])
+		}
+	}
+	return result.String(), nil
+}
+
+// expandParameter expands the expression inside ${...}, either a plain name or a name followed by
+// an operator and a word, the word is only expanded when it is used: ${A:-${B}}. ctx points to the '$'.
+func (e *envParser) expandParameter(expr string, ctx subContext) (string, error) {
+	nameEnd := 0
+	for nameEnd < len(expr) && isVarNameChar(expr[nameEnd]) {
+		nameEnd++
+	}
+	name, rest := expr[:nameEnd], expr[nameEnd:]
+	if name == "" {
+		return "", newSubstitutionErr(ctx.origin, fmt.Sprintf("invalid substitution ${%s}", expr))
+	}
+
+	value, found, err := e.lookupVar(name, ctx)
+	if err != nil {
+		return "", err
+	}
+
+	operator := ""
+	for _, op := range []string{EXPAND_DEFAULT, EXPAND_REQUIRED, EXPAND_ALTERNATE, EXPAND_DEFAULT_UNSET, EXPAND_REQUIRED_UNSET, EXPAND_ALTERNATE_UNSET} {
+		if strings.HasPrefix(rest, op) {
+			operator = op
+			break
+		}
+	}
+	if operator == "" && rest != "" {
+		return "", newSubstitutionErr(ctx.origin, fmt.Sprintf("invalid substitution ${%s}", expr))
+	}
+	word := strings.TrimPrefix(rest, operator)
+	isSet := found && (value != "" || !strings.HasPrefix(operator, ":"))
+	expandWord := func() (string, error) {
+		return e.subValues(word, ctx.after("${"+expr[:len(expr)-len(word)]))
+	}
+
+	switch operator {
+	case EXPAND_DEFAULT, EXPAND_DEFAULT_UNSET:
+		if isSet {
+			return value, nil
+		}
+		return expandWord()
+	case EXPAND_REQUIRED, EXPAND_REQUIRED_UNSET:
+		if isSet {
+			return value, nil
+		}
+		message, err := expandWord()
+		if err != nil {
+			return "", err
+		}
+		if message == "" {
+			message = "not set"
+		}
+		return "", newSubstitutionErr(ctx.origin, fmt.Sprintf("required variable %s: %s", name, message))
+	case EXPAND_ALTERNATE, EXPAND_ALTERNATE_UNSET:
+		if isSet {
+			return expandWord()
+		}
+		return "", nil
+	default:
+		if !found {
+			return "", newSubstitutionErr(ctx.origin, fmt.Sprintf("variable %s not found", name))
+		}
+		return value, nil
+	}
+}
+
+// lookupVar returns the substituted value of a variable as seen by the entry of ctx:
+// the last declaration above the entry, then the first one below it, then earlier files and the fallback.
+// A key referencing itself (PATH=${PATH}:/bin) gets the value it had before this file
+func (e *envParser) lookupVar(varName string, ctx subContext) (string, bool, error) {
+	indexes := e.keyIndex[varName]
+	ref := -1
+	for _, i := range indexes {
+		if i < ctx.index {
+			ref = i
+		} else if i > ctx.index && ref == -1 {
+			ref = i
+			break
+		}
+	}
+	if ref != -1 {
+		value, err := e.substituteEntry(ref, ctx.depth+1)
+		return value, true, err
+	}
+	value, ok := e.getEnv(varName)
+	return value, ok, nil
+}
+
+// returns the index of the '}' closing the expression starting at start, nested ${...} are skipped
+func findClosingBrace(str string, start int, quote rune) int {
+	nesting := 0
+	for i := start; i < len(str); i++ {
+		switch {
+		case str[i] == '\\' && quote == '"':
+			i++
+		case strings.HasPrefix(str[i:], "${"):
+			nesting++
+			i++
+		case str[i] == '}':
+			if nesting == 0 {
+				return i
+			}
+			nesting--
+		}
+	}
+	return -1
+}
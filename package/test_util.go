@@ -1,6 +1,11 @@
 package env_manager
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
 
 func assertEqual[T comparable](t *testing.T, actual, expected T, msg string) {
 	if actual != expected {
@@ -23,9 +28,46 @@ func newTestManager(t *testing.T, file string) *EnvManager {
 }
 
 func newTestParser(t *testing.T, file string) *envParser {
-	parser, err := newEnvParser(file, make(map[string]string))
+	parser, err := newEnvParser(file, make(map[string]string), nil)
 	if err != nil {
 		t.Error(err)
 	}
 	return parser
 }
+
+// writes content to a temporary env file and returns its path
+func newTestEnvFile(t *testing.T, content string) string {
+	file := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// writes env files named by their relative path to a temporary directory and returns it
+func newTestEnvDir(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// returns a manager reading the given env files from an in-memory file system
+func newTestFSManager(t *testing.T, files map[string]string, names ...string) *EnvManager {
+	fsys := fstest.MapFS{}
+	for name, content := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(content)}
+	}
+	manager, err := NewEnvManagerFS(fsys, names...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return manager
+}
@@ -1,35 +1,135 @@
 package env_manager
 
 import (
+	"encoding"
 	"errors"
+	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
 )
 
-func castString(value string, target reflect.Type, delim string) (reflect.Value, error) {
+// EnvDecoder can be implemented by field types to decode themselves from the raw env value,
+// it takes precedence over encoding.TextUnmarshaler
+// example: func (l *LogLevel) DecodeEnv(value string) error
+type EnvDecoder interface {
+	DecodeEnv(value string) error
+}
+
+var (
+	envDecoderType      = reflect.TypeFor[EnvDecoder]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+var errUnsupportedType = errors.New("unsupported type")
+
+// castOptions holds the field tags that change how a value is cast
+type castOptions struct {
+	delim      string // env_delim, separator of slice elements
+	layout     string // env_layout, layout of time.Time values
+	unit       string // env_unit, unit of integer values, eg: bytes
+	kvsep      string // env_kvsep, separator of keys and values in maps, eg: read:100,write:20
+	converters map[reflect.Type]ConverterFunc
+}
+
+func (e *EnvManager) getCastOptions(field reflect.StructField) castOptions {
+	return castOptions{
+		delim:      getDelim(field),
+		layout:     field.Tag.Get(STRUCT_TAG_LAYOUT),
+		unit:       field.Tag.Get(STRUCT_TAG_UNIT),
+		kvsep:      getKeyValueSeparator(field),
+		converters: e.converters,
+	}
+}
+
+// forKeys returns the options of map keys, env_unit only applies to the values
+func (opts castOptions) forKeys() castOptions {
+	opts.unit = ""
+	return opts
+}
+
+func castString(value string, target reflect.Type, opts castOptions) (reflect.Value, error) {
 	var castValue reflect.Value
 	var err error
-	if isPrimitiveKind(target) {
-		castValue, err = castStringToPrimitive(value, target)
+	if converter, ok := opts.converters[target]; ok {
+		castValue, err = castStringWithConverter(value, target, opts, func(value string, _ castOptions) (any, error) {
+			return converter(value)
+		})
+	} else if converter, ok := builtinConverters[target]; ok {
+		castValue, err = castStringWithConverter(value, target, opts, converter)
+	} else if isDecoderType(target) {
+		castValue, err = castStringWithDecoder(value, target)
+	} else if isPrimitiveKind(target) {
+		castValue, err = castStringToPrimitive(value, target, opts)
 	} else if target.Kind() == reflect.Slice {
-		castValue, err = castStringToSlice(value, target.Elem(), delim)
+		castValue, err = castStringToSlice(value, target.Elem(), opts)
+	} else if target.Kind() == reflect.Map {
+		castValue, err = castStringToMap(value, target, opts)
+	} else if target.Kind() == reflect.Pointer {
+		castValue, err = castString(value, target.Elem(), opts)
+		if err == nil {
+			ptrValue := reflect.New(target.Elem())
+			ptrValue.Elem().Set(castValue)
+			castValue = ptrValue
+		}
 	} else {
-		return reflect.Value{}, errors.New("unsupported type")
+		return reflect.Value{}, errUnsupportedType
 	}
 	if err != nil {
-		return reflect.Value{}, newTypeCastErr(value, target.Name(), err)
+		if errors.Is(err, errUnsupportedType) {
+			return reflect.Value{}, err
+		}
+		// errors of elements, keys and primitives are already cast errors
+		if envErr, ok := err.(*EnvError); ok {
+			return reflect.Value{}, envErr
+		}
+		return reflect.Value{}, newTypeCastErr(value, target.String(), err)
 	} else {
 		return castValue, nil
 	}
 }
 
-func castStringToSlice(value string, targetElement reflect.Type, delim string) (reflect.Value, error) {
-	parts := strings.Split(value, delim)
+// checks if the type is decoded as a whole by a converter or a decoder,
+// these types are not bound field by field even when they are structs or maps
+func hasCustomCast(target reflect.Type, opts castOptions) bool {
+	if _, ok := opts.converters[target]; ok {
+		return true
+	}
+	if _, ok := builtinConverters[target]; ok || isDecoderType(target) {
+		return true
+	}
+	return target.Kind() == reflect.Pointer && hasCustomCast(target.Elem(), opts)
+}
+
+// checks if the type, or a pointer to it, implements EnvDecoder or encoding.TextUnmarshaler
+func isDecoderType(target reflect.Type) bool {
+	ptrType := reflect.PointerTo(target)
+	return ptrType.Implements(envDecoderType) || ptrType.Implements(textUnmarshalerType)
+}
+
+func castStringWithDecoder(value string, target reflect.Type) (reflect.Value, error) {
+	ptrValue := reflect.New(target)
+	var err error
+	switch decoder := ptrValue.Interface().(type) {
+	case EnvDecoder:
+		err = decoder.DecodeEnv(value)
+	case encoding.TextUnmarshaler:
+		err = decoder.UnmarshalText([]byte(value))
+	default:
+		err = errUnsupportedType
+	}
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return ptrValue.Elem(), nil
+}
+
+func castStringToSlice(value string, targetElement reflect.Type, opts castOptions) (reflect.Value, error) {
+	parts := strings.Split(value, opts.delim)
 	slice := reflect.MakeSlice(reflect.SliceOf(targetElement), len(parts), len(parts))
 	for i, part := range parts {
 		part = strings.TrimSpace(part)
-		elemValue, elemErr := castStringToPrimitive(part, targetElement)
+		elemValue, elemErr := castString(part, targetElement, opts)
 		if elemErr != nil {
 			return reflect.Value{}, elemErr
 		}
@@ -38,10 +138,42 @@ func castStringToSlice(value string, targetElement reflect.Type, delim string) (
 	return slice, nil
 }
 
-func castStringToPrimitive(value string, target reflect.Type) (reflect.Value, error) {
+// castStringToMap casts pairs like read:100,write:20, keys and values are cast to the map types
+func castStringToMap(value string, target reflect.Type, opts castOptions) (reflect.Value, error) {
+	mapValue := reflect.MakeMap(target)
+	if strings.TrimSpace(value) == "" {
+		return mapValue, nil
+	}
+	keyOpts := opts.forKeys()
+	for _, pair := range strings.Split(value, opts.delim) {
+		key, val, found := strings.Cut(pair, opts.kvsep)
+		if !found {
+			return reflect.Value{}, fmt.Errorf("pair %q has no separator %q", strings.TrimSpace(pair), opts.kvsep)
+		}
+		keyValue, err := castString(strings.TrimSpace(key), target.Key(), keyOpts)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		elemValue, err := castString(strings.TrimSpace(val), target.Elem(), opts)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		mapValue.SetMapIndex(keyValue, elemValue)
+	}
+	return mapValue, nil
+}
+
+func castStringToPrimitive(value string, target reflect.Type, opts castOptions) (reflect.Value, error) {
 	var err error
 	var castValue any
 
+	rawValue := value
+	if opts.unit != "" {
+		if value, err = convertUnit(value, opts.unit, target); err != nil {
+			return reflect.Value{}, newTypeCastErr(rawValue, target.Name(), err)
+		}
+	}
+
 	switch target.Kind() {
 	case reflect.String:
 		castValue = value
@@ -81,7 +213,8 @@ func castStringToPrimitive(value string, target reflect.Type) (reflect.Value, er
 		err = errors.New("unsupported type")
 	}
 	if err != nil {
-		return reflect.Value{}, newTypeCastErr(value, target.Name(), err)
+		return reflect.Value{}, newTypeCastErr(rawValue, target.Name(), err)
 	}
-	return reflect.ValueOf(castValue), nil
+	// the parsed values are the widest types (int64, uint64...), convert them to the exact field type
+	return reflect.ValueOf(castValue).Convert(target), nil
 }
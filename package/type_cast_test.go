@@ -0,0 +1,230 @@
+package env_manager
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testLogFormat int
+
+const (
+	testLogText testLogFormat = iota
+	testLogJSON
+)
+
+func (f *testLogFormat) DecodeEnv(value string) error {
+	switch value {
+	case "text":
+		*f = testLogText
+	case "json":
+		*f = testLogJSON
+	default:
+		return fmt.Errorf("unknown log format %s", value)
+	}
+	return nil
+}
+
+type TestDecoderStruct struct {
+	IP        net.IP                `env:"IP"`
+	Addr      netip.Addr            `env:"ADDR"`
+	Peers     []netip.Addr          `env:"PEERS"`
+	Supply    *big.Int              `env:"SUPPLY"`
+	Level     slog.Level            `env:"LEVEL"`
+	Format    testLogFormat         `env:"FORMAT"`
+	Formats   []*testLogFormat      `env:"FORMATS"`
+	Levels    map[string]slog.Level `env_keys:"LEVEL,DEBUG_LEVEL"`
+	Missing   *netip.Addr           `env:"GO_ENV_MANAGER_MISSING_ADDR"`
+	SmallInt  int8                  `env:"SMALL_INT"`
+	SmallUint uint16                `env:"SMALL_UINT"`
+}
+
+func TestCastWithDecoders(t *testing.T) {
+	content := "IP=10.0.0.1\nADDR=::1\nPEERS=10.0.0.2,10.0.0.3\nSUPPLY=123456789012345678901234567890\n" +
+		"LEVEL=warn\nDEBUG_LEVEL=debug\nFORMAT=json\nFORMATS=text,json\nSMALL_INT=-12\nSMALL_UINT=65535"
+	envBinder := new(TestDecoderStruct)
+	if err := newTestManager(t, newTestEnvFile(t, content)).SetBindMode(BIND_FILE_WINS).BindEnv(envBinder); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCondition(t, envBinder.IP.Equal(net.ParseIP("10.0.0.1")), "Invalid IP")
+	assertEqual(t, envBinder.Addr, netip.MustParseAddr("::1"), "Invalid Addr")
+	assertEqual(t, len(envBinder.Peers), 2, "Invalid number of Peers")
+	assertEqual(t, envBinder.Peers[1], netip.MustParseAddr("10.0.0.3"), "Invalid Peers element")
+	assertEqual(t, envBinder.Supply.String(), "123456789012345678901234567890", "Invalid Supply")
+	assertEqual(t, envBinder.Level, slog.LevelWarn, "Invalid Level")
+	assertEqual(t, envBinder.Format, testLogJSON, "Invalid Format from EnvDecoder")
+	assertEqual(t, *envBinder.Formats[0], testLogText, "Invalid Formats element")
+	assertEqual(t, envBinder.Levels["DEBUG_LEVEL"], slog.LevelDebug, "Invalid Levels map value")
+	assertCondition(t, envBinder.Missing == nil, "Missing pointer must stay nil")
+	assertEqual(t, envBinder.SmallInt, int8(-12), "Invalid SmallInt")
+	assertEqual(t, envBinder.SmallUint, uint16(65535), "Invalid SmallUint")
+
+	err := newTestManager(t, newTestEnvFile(t, content+"\nFORMAT=yaml")).SetMode(SILENT).SetBindMode(BIND_FILE_WINS).BindEnv(new(TestDecoderStruct))
+	assertCondition(t, err != nil, "Decoder errors must be returned")
+}
+
+type TestBuiltinConvertersStruct struct {
+	Endpoint  *url.URL            `env:"ENDPOINT"`
+	StartedAt time.Time           `env:"STARTED_AT"`
+	ReleaseOn time.Time           `env:"RELEASE_ON" env_layout:"2006-01-02"`
+	Zone      *time.Location      `env:"ZONE"`
+	Pattern   *regexp.Regexp      `env:"PATTERN"`
+	Mode      os.FileMode         `env:"MODE"`
+	Subnet    netip.Prefix        `env:"SUBNET"`
+	Timeouts  []time.Duration     `env:"TIMEOUTS"`
+	Holidays  []time.Time         `env:"HOLIDAYS" env_layout:"2006-01-02" env_delim:";"`
+	Mirrors   map[string]*url.URL `env_keys:"ENDPOINT"`
+}
+
+func TestCastWithBuiltinConverters(t *testing.T) {
+	content := "ENDPOINT=https://api.example.com/v1\nSTARTED_AT=2024-05-01T10:00:00Z\nRELEASE_ON=2024-12-24\n" +
+		"ZONE=UTC\nPATTERN=^v[0-9]+$\nMODE=0640\nSUBNET=10.0.0.0/8\nTIMEOUTS=1s,2m\nHOLIDAYS=2024-12-25;2025-01-01"
+	envBinder := new(TestBuiltinConvertersStruct)
+	if err := newTestManager(t, newTestEnvFile(t, content)).SetBindMode(BIND_FILE_WINS).BindEnv(envBinder); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, envBinder.Endpoint.Host, "api.example.com", "Invalid Endpoint")
+	assertEqual(t, envBinder.StartedAt, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), "Invalid StartedAt")
+	assertEqual(t, envBinder.ReleaseOn, time.Date(2024, 12, 24, 0, 0, 0, 0, time.UTC), "Invalid ReleaseOn")
+	assertEqual(t, envBinder.Zone, time.UTC, "Invalid Zone")
+	assertCondition(t, envBinder.Pattern.MatchString("v12"), "Invalid Pattern")
+	assertEqual(t, envBinder.Mode, os.FileMode(0o640), "Invalid Mode")
+	assertEqual(t, envBinder.Subnet, netip.MustParsePrefix("10.0.0.0/8"), "Invalid Subnet")
+	assertEqual(t, envBinder.Timeouts[1], 2*time.Minute, "Invalid Timeouts element")
+	assertEqual(t, envBinder.Holidays[1].Year(), 2025, "Invalid Holidays element")
+	assertEqual(t, envBinder.Mirrors["ENDPOINT"].Path, "/v1", "Invalid Mirrors value")
+}
+
+type testMoney struct {
+	Cents int64
+}
+
+type TestRegisterConverterStruct struct {
+	Price   testMoney            `env:"PRICE"`
+	Prices  []testMoney          `env:"PRICES"`
+	Limits  map[string]testMoney `env_keys:"PRICE"`
+	Enabled bool                 `env:"ENABLED"`
+	Flags   []*bool              `env:"FLAGS"`
+}
+
+func TestRegisterConverter(t *testing.T) {
+	parseMoney := func(value string) (any, error) {
+		units, cents, _ := strings.Cut(value, ".")
+		amount, err := strconv.ParseInt(units+cents, 10, 64)
+		return testMoney{amount}, err
+	}
+	parseYesNo := func(value string) (any, error) {
+		return value == "yes", nil
+	}
+	file := newTestEnvFile(t, "PRICE=12.34\nPRICES=1.00,2.50\nENABLED=yes\nFLAGS=yes,no")
+
+	envBinder := new(TestRegisterConverterStruct)
+	envManager := newTestManager(t, file).SetBindMode(BIND_FILE_WINS).
+		RegisterConverter(reflect.TypeFor[testMoney](), parseMoney).
+		RegisterConverter(reflect.TypeFor[bool](), parseYesNo)
+	if err := envManager.BindEnv(envBinder); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, envBinder.Price, testMoney{1234}, "Invalid Price")
+	assertEqual(t, envBinder.Prices[1], testMoney{250}, "Converter must apply to slice elements")
+	assertEqual(t, envBinder.Limits["PRICE"], testMoney{1234}, "Converter must apply to map values")
+	assertEqual(t, envBinder.Enabled, true, "Converter must take precedence over the bool kind")
+	assertEqual(t, *envBinder.Flags[1], false, "Converter must apply behind pointers")
+
+	err := newTestManager(t, file).SetMode(SILENT).SetBindMode(BIND_FILE_WINS).BindEnv(new(TestRegisterConverterStruct))
+	assertCondition(t, err != nil, "Converters must be scoped to the manager they are registered on")
+}
+
+type TestByteSizeStruct struct {
+	MaxUpload  int64            `env:"MAX_UPLOAD" env_unit:"bytes"`
+	BufferSize uint32           `env:"BUFFER_SIZE" env_unit:"bytes"`
+	CacheSize  int              `env:"CACHE_SIZE" env_unit:"bytes"`
+	Plain      int              `env:"PLAIN" env_unit:"bytes"`
+	Limits     []uint64         `env:"LIMITS" env_unit:"bytes"`
+	Quotas     map[string]int64 `env:"QUOTAS" env_unit:"bytes"`
+	Shards     map[int]int64    `env:"SHARDS" env_unit:"bytes"`
+	Buffers    map[string]int   `env_keys:"BUFFER_*" env_unit:"bytes"`
+}
+
+type TestByteSizeOverflowStruct struct {
+	Small   int8   `env:"SMALL" env_unit:"bytes"`
+	Port    uint16 `env:"PORT_SIZE" env_unit:"bytes"`
+	Partial int    `env:"PARTIAL" env_unit:"bytes"`
+	Invalid int    `env:"INVALID" env_unit:"bytes"`
+}
+
+func TestByteSizeUnit(t *testing.T) {
+	content := "MAX_UPLOAD=10MB\nBUFFER_SIZE=512KiB\nCACHE_SIZE=1.5G\nPLAIN=4096\nLIMITS=1kb,2Mi\n" +
+		"QUOTAS=alice:1GiB,bob:512MB\nSHARDS=1:1KB,2:2KB"
+	envBinder := new(TestByteSizeStruct)
+	if err := newTestManager(t, newTestEnvFile(t, content)).SetBindMode(BIND_FILE_WINS).BindEnv(envBinder); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, envBinder.MaxUpload, 10_000_000, "Invalid MaxUpload")
+	assertEqual(t, envBinder.BufferSize, 512*1024, "Invalid BufferSize")
+	assertEqual(t, envBinder.CacheSize, 1_500_000_000, "Invalid CacheSize")
+	assertEqual(t, envBinder.Plain, 4096, "Values without a unit must be bytes")
+	assertEqual(t, envBinder.Limits[1], 2*1024*1024, "Invalid Limits element")
+	assertEqual(t, envBinder.Quotas["alice"], 1<<30, "The unit must apply to the map values")
+	assertEqual(t, envBinder.Shards[2], 2000, "Integer map keys must be cast without the unit")
+	assertEqual(t, envBinder.Buffers["BUFFER_SIZE"], 512*1024, "The unit must apply to the values of env_keys maps")
+
+	content = "SMALL=1KB\nPORT_SIZE=64KiB\nPARTIAL=1.5B\nINVALID=1/2MB"
+	err := newTestManager(t, newTestEnvFile(t, content)).SetMode(SILENT).SetBindMode(BIND_FILE_WINS).BindEnv(new(TestByteSizeOverflowStruct))
+	var envErrs EnvErrors
+	if !errors.As(err, &envErrs) {
+		t.Fatalf("Expected EnvErrors got %v", err)
+	}
+	assertEqual(t, len(envErrs), 4, "Overflowing and invalid sizes must be reported")
+}
+
+type TestKeyValueMapStruct struct {
+	Limits   map[string]int           `env:"LIMITS"`
+	Timeouts map[int]time.Duration    `env:"TIMEOUTS" env_delim:";" env_kvsep:"="`
+	Weights  *map[testLogFormat]uint8 `env:"WEIGHTS"`
+	Empty    map[string]string        `env:"EMPTY" env_def:""`
+}
+
+func TestKeyValueMap(t *testing.T) {
+	content := "LIMITS=\"read:100, write: 20\"\nTIMEOUTS=\"1=5s;2=1m\"\nWEIGHTS=text:1,json:3"
+	envBinder := new(TestKeyValueMapStruct)
+	if err := newTestManager(t, newTestEnvFile(t, content)).SetBindMode(BIND_FILE_WINS).BindEnv(envBinder); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, len(envBinder.Limits), 2, "Invalid number of Limits")
+	assertEqual(t, envBinder.Limits["write"], 20, "Invalid Limits value")
+	assertEqual(t, envBinder.Timeouts[2], time.Minute, "Keys and values must be cast with env_kvsep and env_delim")
+	assertEqual(t, (*envBinder.Weights)[testLogJSON], 3, "Keys must go through the decoders")
+	assertEqual(t, len(envBinder.Empty), 0, "Empty values must give an empty map")
+
+	err := newTestManager(t, newTestEnvFile(t, "LIMITS=read,write:20\nTIMEOUTS=\"1=5s\"")).SetMode(SILENT).SetBindMode(BIND_FILE_WINS).BindEnv(new(TestKeyValueMapStruct))
+	assertCondition(t, errors.Is(err, &EnvError{Type: TYPE_CAST_ERROR}), "Pairs without separator must be type cast errors")
+}
+
+func TestNestedCastErrors(t *testing.T) {
+	opts := castOptions{delim: ",", kvsep: ":"}
+	for _, target := range []reflect.Type{reflect.TypeOf([]int{}), reflect.TypeOf(&[]int{}), reflect.TypeOf(map[string]int{})} {
+		value := "1,x,3"
+		if target.Kind() == reflect.Map {
+			value = "a:1,b:x"
+		}
+		_, err := castString(value, target, opts)
+		assertCondition(t, errors.Is(err, &EnvError{Type: TYPE_CAST_ERROR}), "Invalid elements must be type cast errors")
+		assertEqual(t, strings.Count(err.Error(), "cannot be casted"), 1, "Cast errors must not be wrapped again, got "+err.Error())
+		assertCondition(t, strings.Contains(err.Error(), "x cannot be casted to type int"), "Errors must cite the invalid element, got "+err.Error())
+	}
+}
@@ -0,0 +1,90 @@
+package env_manager
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+// Units supported by the env_unit tag
+const (
+	UNIT_BYTES = "bytes" // byte quantities like 10MB, 512KiB or 1.5G
+)
+
+// decimal (SI) and binary (IEC) multipliers of byte units, units are case insensitive
+var byteUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"k":   1_000,
+	"kb":  1_000,
+	"m":   1_000_000,
+	"mb":  1_000_000,
+	"g":   1_000_000_000,
+	"gb":  1_000_000_000,
+	"t":   1_000_000_000_000,
+	"tb":  1_000_000_000_000,
+	"p":   1_000_000_000_000_000,
+	"pb":  1_000_000_000_000_000,
+	"e":   1_000_000_000_000_000_000,
+	"eb":  1_000_000_000_000_000_000,
+	"ki":  1 << 10,
+	"kib": 1 << 10,
+	"mi":  1 << 20,
+	"mib": 1 << 20,
+	"gi":  1 << 30,
+	"gib": 1 << 30,
+	"ti":  1 << 40,
+	"tib": 1 << 40,
+	"pi":  1 << 50,
+	"pib": 1 << 50,
+	"ei":  1 << 60,
+	"eib": 1 << 60,
+}
+
+// convertUnit converts a value with a unit to a plain integer string, so that it goes through
+// the usual strconv parsing of the target kind which checks the overflow for its bit size
+func convertUnit(value, unit string, target reflect.Type) (string, error) {
+	if !isIntegerKind(target) {
+		return "", fmt.Errorf("env_unit is only supported for integer types")
+	}
+	switch unit {
+	case UNIT_BYTES:
+		return parseByteSize(value)
+	default:
+		return "", fmt.Errorf("unknown unit %s", unit)
+	}
+}
+
+// parseByteSize converts sizes like 10MB, 512KiB or 1.5G to the number of bytes
+func parseByteSize(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	numEnd := strings.LastIndexAny(value, "0123456789.") + 1
+	number, suffix := value[:numEnd], strings.ToLower(strings.TrimSpace(value[numEnd:]))
+
+	multiplier, ok := byteUnits[suffix]
+	if !ok {
+		return "", fmt.Errorf("unknown byte unit %q", suffix)
+	}
+	digits := strings.TrimLeft(number, "+-")
+	invalidDigit := strings.IndexFunc(digits, func(r rune) bool { return r != '.' && (r < '0' || r > '9') })
+	size, ok := new(big.Rat).SetString(number)
+	if !ok || digits == "" || invalidDigit != -1 {
+		return "", fmt.Errorf("invalid byte size %q", value)
+	}
+	size.Mul(size, new(big.Rat).SetInt64(multiplier))
+	if !size.IsInt() {
+		return "", fmt.Errorf("byte size %q is not a whole number of bytes", value)
+	}
+	return size.Num().String(), nil
+}
+
+func isIntegerKind(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	default:
+		return false
+	}
+}
@@ -2,7 +2,10 @@ package env_manager
 
 import (
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"unicode"
@@ -12,12 +15,50 @@ func (e *envParser) getEnv(key string) (string, bool) {
 	if value, ok := e.env[key]; ok {
 		return value, true
 	} else {
-		return os.LookupEnv(key)
+		return e.fallback.Lookup(key)
 	}
 }
 
-func openFile(fileName string) (string, error) {
-	content, err := os.ReadFile(fileName)
+// checks if a variable name starts at index i of str, names are [A-Za-z_][A-Za-z0-9_]*
+func isVarNameStart(str string, i int) bool {
+	return i < len(str) && (str[i] == '_' || ('a' <= str[i] && str[i] <= 'z') || ('A' <= str[i] && str[i] <= 'Z'))
+}
+
+func isVarNameChar(ch byte) bool {
+	return ch == '_' || ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ('0' <= ch && ch <= '9')
+}
+
+// openFile opens an env file of fsys, or of the os file system when fsys is nil
+func openFile(fsys fs.FS, fileName string) (io.ReadCloser, error) {
+	var file io.ReadCloser
+	var err error
+	if fsys == nil {
+		file, err = os.Open(fileName)
+	} else {
+		file, err = fsys.Open(fileName)
+	}
+	if err != nil {
+		return nil, newConfigError(fmt.Errorf("error reading file %s: %v", fileName, err))
+	}
+	return file, nil
+}
+
+// statFile returns the info of an env file of fsys, or of the os file system when fsys is nil
+func statFile(fsys fs.FS, fileName string) (fs.FileInfo, error) {
+	if fsys == nil {
+		return os.Stat(fileName)
+	}
+	return fs.Stat(fsys, fileName)
+}
+
+// readFile reads a whole env file of fsys, or of the os file system when fsys is nil
+func readFile(fsys fs.FS, fileName string) (string, error) {
+	file, err := openFile(fsys, fileName)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
 	if err != nil {
 		return "", newConfigError(fmt.Errorf("error reading file %s: %v", fileName, err))
 	}
@@ -59,6 +100,51 @@ func pascalToSnakeCase(str string) string {
 	return result.String()
 }
 
+// Converts SNAKE_CASE to camelCase: MAX_CONNS -> maxConns
+func snakeToCamelCase(str string) string {
+	var result strings.Builder
+	for i, part := range strings.Split(strings.ToLower(str), "_") {
+		if i > 0 && part != "" {
+			runes := []rune(part)
+			runes[0] = unicode.ToUpper(runes[0])
+			part = string(runes)
+		}
+		result.WriteString(part)
+	}
+	return result.String()
+}
+
+type mapKeyOptions struct {
+	strip   bool
+	keyCase string
+}
+
+func parseMapKeyOptions(tag string) mapKeyOptions {
+	opts := mapKeyOptions{}
+	for _, part := range strings.Split(tag, ",") {
+		switch part = strings.TrimSpace(part); part {
+		case MAP_KEY_STRIP:
+			opts.strip = true
+		case MAP_KEY_LOWER, MAP_KEY_UPPER, MAP_KEY_CAMEL:
+			opts.keyCase = part
+		}
+	}
+	return opts
+}
+
+func (opts mapKeyOptions) transform(key string) string {
+	switch opts.keyCase {
+	case MAP_KEY_LOWER:
+		return strings.ToLower(key)
+	case MAP_KEY_UPPER:
+		return strings.ToUpper(key)
+	case MAP_KEY_CAMEL:
+		return snakeToCamelCase(key)
+	default:
+		return key
+	}
+}
+
 // IsPrimitive checks whether the type is a Go primitive type.
 func isPrimitiveKind(t reflect.Type) bool {
 	switch t.Kind() {
@@ -82,8 +168,12 @@ func getDelim(field reflect.StructField) string {
 	return delim
 }
 
-func checkType(typ reflect.Type, fullTypeName string) bool {
-	return typ.PkgPath()+"."+typ.Name() == fullTypeName
+func getKeyValueSeparator(field reflect.StructField) string {
+	kvsep := field.Tag.Get(STRUCT_TAG_KV_SEPARATOR)
+	if kvsep == "" {
+		kvsep = ":"
+	}
+	return kvsep
 }
 
 func getDefaultValue(field reflect.StructField) *string {
@@ -94,3 +184,59 @@ func getDefaultValue(field reflect.StructField) *string {
 		return nil
 	}
 }
+
+// returns the name of the struct type pointed by ptr, used as the root of field paths
+func structName(ptr any) string {
+	typ := reflect.TypeOf(ptr)
+	if typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ == nil {
+		return ""
+	}
+	return typ.Name()
+}
+
+func joinFieldPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+func joinPrefix(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "_" + key
+}
+
+func hasKeyWithPrefix(keys []string, prefix string) bool {
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// checks if the type is a struct, or pointer to struct, that is bound field by field
+func isStructType(typ reflect.Type, opts castOptions) bool {
+	if hasCustomCast(typ, opts) {
+		return false
+	}
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Struct
+}
+
+// reports if two paths point to the same file
+func isSameFile(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return absA == absB
+}
@@ -0,0 +1,204 @@
+package env_manager
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Rules supported by the env_validate tag
+// example: `env_validate:"required,min=1,max=65535"`
+const (
+	VALIDATE_REQUIRED = "required" // value must not be empty, zero or nil
+	VALIDATE_MIN      = "min"      // minimum number, or minimum length for strings, slices and maps
+	VALIDATE_MAX      = "max"      // maximum number, or maximum length for strings, slices and maps
+	VALIDATE_ONEOF    = "oneof"    // value must be one of the '|' separated options
+	VALIDATE_REGEX    = "regex"    // value must match the pattern, must be the last rule as the pattern may contain ','
+	VALIDATE_URL      = "url"      // value must be an absolute url with a scheme and a host
+	VALIDATE_EMAIL    = "email"    // value must be a plain email address
+	VALIDATE_HOSTPORT = "hostport" // value must be a host:port pair
+	VALIDATE_PORT     = "port"     // value must be a port number between 1 and 65535
+)
+
+type validationRule struct {
+	name  string
+	param string
+}
+
+// parses the env_validate tag into rules, the regex rule takes the rest of the tag as its pattern
+func parseValidationRules(tag string) []validationRule {
+	rules := []validationRule{}
+	for tag != "" {
+		part := tag
+		if strings.HasPrefix(tag, VALIDATE_REGEX+"=") {
+			tag = ""
+		} else if before, after, found := strings.Cut(tag, ","); found {
+			part, tag = before, after
+		} else {
+			tag = ""
+		}
+		name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			rules = append(rules, validationRule{name, param})
+		}
+	}
+	return rules
+}
+
+// validateField checks the cast value of a field against the rules of its env_validate tag
+func validateField(field reflect.StructField, value reflect.Value) error {
+	tag := field.Tag.Get(STRUCT_TAG_VALIDATE)
+	if tag == "" {
+		return nil
+	}
+	for _, rule := range parseValidationRules(tag) {
+		if err := rule.check(value); err != nil {
+			return newValidationErr(field.Name, rule.String(), err)
+		}
+	}
+	return nil
+}
+
+func (r validationRule) String() string {
+	if r.param == "" {
+		return r.name
+	}
+	return r.name + "=" + r.param
+}
+
+func (r validationRule) check(value reflect.Value) error {
+	if r.name == VALIDATE_REQUIRED {
+		if !value.IsValid() || value.IsZero() || (isCollectionKind(value) && value.Len() == 0) {
+			return fmt.Errorf("value is required")
+		}
+		return nil
+	}
+	// other rules apply to the pointed value, unset pointers are only checked by required
+	for value.IsValid() && value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if !value.IsValid() {
+		return nil
+	}
+
+	switch r.name {
+	case VALIDATE_MIN, VALIDATE_MAX:
+		return r.checkBound(value)
+	case VALIDATE_ONEOF, VALIDATE_REGEX, VALIDATE_URL, VALIDATE_EMAIL, VALIDATE_HOSTPORT, VALIDATE_PORT:
+		// string rules apply to each element of slices
+		if value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+			for i := range value.Len() {
+				if err := r.checkString(formatValue(value.Index(i))); err != nil {
+					return fmt.Errorf("element %d: %v", i, err)
+				}
+			}
+			return nil
+		}
+		return r.checkString(formatValue(value))
+	default:
+		return fmt.Errorf("unknown validation rule %s", r.name)
+	}
+}
+
+func (r validationRule) checkBound(value reflect.Value) error {
+	bound, err := strconv.ParseFloat(r.param, 64)
+	if err != nil {
+		return fmt.Errorf("invalid bound %q", r.param)
+	}
+
+	var actual float64
+	what := "value"
+	switch {
+	case value.CanInt():
+		actual = float64(value.Int())
+	case value.CanUint():
+		actual = float64(value.Uint())
+	case value.CanFloat():
+		actual = value.Float()
+	case value.Kind() == reflect.String:
+		actual, what = float64(utf8.RuneCountInString(value.String())), "length"
+	case isCollectionKind(value):
+		actual, what = float64(value.Len()), "length"
+	default:
+		return fmt.Errorf("%s is not supported for type %s", r.name, value.Type())
+	}
+
+	if r.name == VALIDATE_MIN && actual < bound {
+		return fmt.Errorf("%s %v is less than %v", what, actual, bound)
+	}
+	if r.name == VALIDATE_MAX && actual > bound {
+		return fmt.Errorf("%s %v is greater than %v", what, actual, bound)
+	}
+	return nil
+}
+
+func (r validationRule) checkString(str string) error {
+	switch r.name {
+	case VALIDATE_ONEOF:
+		options := strings.Split(r.param, "|")
+		if !slices.Contains(options, str) {
+			return fmt.Errorf("%q is not one of %s", str, strings.Join(options, ", "))
+		}
+	case VALIDATE_REGEX:
+		pattern, err := regexp.Compile(r.param)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", r.param, err)
+		}
+		if !pattern.MatchString(str) {
+			return fmt.Errorf("%q does not match %s", str, r.param)
+		}
+	case VALIDATE_URL:
+		if u, err := url.Parse(str); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("%q is not an absolute url", str)
+		}
+	case VALIDATE_EMAIL:
+		if address, err := mail.ParseAddress(str); err != nil || address.Address != str {
+			return fmt.Errorf("%q is not an email address", str)
+		}
+	case VALIDATE_HOSTPORT:
+		host, port, err := net.SplitHostPort(str)
+		if err != nil || host == "" {
+			return fmt.Errorf("%q is not a host:port pair", str)
+		}
+		if err := checkPort(port); err != nil {
+			return err
+		}
+	case VALIDATE_PORT:
+		return checkPort(str)
+	}
+	return nil
+}
+
+func checkPort(str string) error {
+	if port, err := strconv.ParseUint(str, 10, 16); err != nil || port == 0 {
+		return fmt.Errorf("%q is not a port between 1 and 65535", str)
+	}
+	return nil
+}
+
+func isCollectionKind(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.String:
+		return true
+	default:
+		return false
+	}
+}
+
+// formats a value as a string for the string based rules
+func formatValue(value reflect.Value) string {
+	if value.Kind() == reflect.String {
+		return value.String()
+	}
+	return fmt.Sprint(value.Interface())
+}
@@ -0,0 +1,40 @@
+package env_manager
+
+import (
+	"errors"
+	"testing"
+)
+
+type TestValidationStruct struct {
+	Port     int      `env:"PORT" env_validate:"required,port"`
+	Env      string   `env:"ENV" env_validate:"oneof=dev|staging|production"`
+	Name     string   `env:"NAME" env_validate:"min=3,max=8,regex=^[a-z]+(,[a-z]+)?$"`
+	URL      string   `env:"URL" env_validate:"url"`
+	Email    string   `env:"EMAIL" env_validate:"email"`
+	Addr     string   `env:"ADDR" env_validate:"hostport"`
+	Ratio    float64  `env:"RATIO" env_validate:"min=0,max=1"`
+	Hosts    []string `env:"HOSTS" env_validate:"min=1,hostport"`
+	Optional *string  `env:"OPTIONAL" env_validate:"required"`
+}
+
+func TestValidationRules(t *testing.T) {
+	valid := "PORT=8080\nENV=staging\nNAME=abc,de\nURL=https://example.com/path\nEMAIL=ops@example.com\n" +
+		"ADDR=localhost:5432\nRATIO=0.5\nHOSTS=a:1,b:2\nOPTIONAL=set"
+	envBinder := new(TestValidationStruct)
+	if err := newTestManager(t, newTestEnvFile(t, valid)).SetMode(SILENT).SetBindMode(BIND_FILE_WINS).BindEnv(envBinder); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, envBinder.Name, "abc,de", "Regex containing ',' must be parsed as a single rule")
+
+	invalid := "PORT=70000\nENV=test\nNAME=ab\nURL=example.com\nEMAIL=Ops <ops@example.com>\n" +
+		"ADDR=localhost\nRATIO=1.5\nHOSTS=a:1,b"
+	err := newTestManager(t, newTestEnvFile(t, invalid)).SetMode(SILENT).SetBindMode(BIND_FILE_WINS).BindEnv(new(TestValidationStruct))
+	var envErrs EnvErrors
+	if !errors.As(err, &envErrs) {
+		t.Fatalf("Expected EnvErrors got %v", err)
+	}
+	assertEqual(t, len(envErrs), 9, "Every invalid field must be reported")
+	for _, envErr := range envErrs {
+		assertEqual(t, envErr.Type, VALIDATION_ERROR, "Invalid error type for "+envErr.Field)
+	}
+}
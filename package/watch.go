@@ -0,0 +1,194 @@
+package env_manager
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const DEFAULT_WATCH_INTERVAL = time.Second
+
+// Kinds of changes reported by Watch
+const (
+	CHANGE_ADDED = iota + 1
+	CHANGE_UPDATED
+	CHANGE_REMOVED
+)
+
+// Change is the difference of a single key between two parses of the env files
+type Change struct {
+	Key      string
+	Kind     int
+	OldValue string
+	NewValue string
+}
+
+// SetWatchInterval sets how often Watch checks the env files for changes
+func (e *EnvManager) SetWatchInterval(interval time.Duration) *EnvManager {
+	if interval <= 0 {
+		interval = DEFAULT_WATCH_INTERVAL
+	}
+	e.watchInterval = interval
+	return e
+}
+
+// Watched holds the latest bound copy of a struct watched with Watch, it is safe to read from any goroutine.
+// Every reload stores a new copy, the copies returned by Load are never modified
+type Watched[T any] struct {
+	value atomic.Pointer[T]
+}
+
+// NewWatched binds a first copy of T with the manager and returns it ready to be passed to Watch
+func NewWatched[T any](e *EnvManager) (*Watched[T], error) {
+	value := new(T)
+	if err := e.BindEnv(value); err != nil {
+		return nil, err
+	}
+	watched := &Watched[T]{}
+	watched.value.Store(value)
+	return watched, nil
+}
+
+// Load returns the latest bound copy, nil when nothing was bound yet
+func (w *Watched[T]) Load() *T {
+	return w.value.Load()
+}
+
+func (w *Watched[T]) newValue() any {
+	return new(T)
+}
+
+func (w *Watched[T]) store(value any) {
+	w.value.Store(value.(*T))
+}
+
+// WatchTarget receives the copies bound by Watch, it is implemented by *Watched
+type WatchTarget interface {
+	newValue() any
+	store(value any)
+}
+
+// Watch polls the env files of the manager and binds a new copy of the watched struct when their content changes.
+// The copy is only published to watched when binding succeeds, so a broken edit never leaves readers with a half
+// updated struct, and published copies are never modified so readers need no locking. onChange is then called
+// with the per key diff.
+// When the bind mode is BIND_OS_ONLY the files are parsed when Watch starts and the new values are bound
+// as BIND_FILE_WINS, the process environment is not modified.
+// Watch blocks until ctx is done and returns ctx.Err()
+func (e *EnvManager) Watch(ctx context.Context, watched WatchTarget, onChange func([]Change)) error {
+	if watched == nil || reflect.ValueOf(watched).IsNil() {
+		return newInvalidUsageErr("watched varaible", "watched variable must not be nil")
+	}
+
+	modTimes := e.fileStates()
+	// the parsed files are the baseline of the diffs, managers that never parsed them (BIND_OS_ONLY)
+	// parse them now so the first change only reports the real edits
+	e.mu.Lock()
+	if len(e.envMap) == 0 {
+		if err := e.parseEnv(); err != nil {
+			e.Log(HIGH, "Error parsing env files, the first change is diffed with an empty baseline: %v", err)
+		}
+	}
+	e.mu.Unlock()
+
+	ticker := time.NewTicker(e.watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			current := e.fileStates()
+			if slices.EqualFunc(current, modTimes, fileState.equal) {
+				continue
+			}
+			modTimes = current
+			e.Log(MED, "Env files changed, rebinding environment variables")
+			changes, err := e.rebind(watched)
+			if err != nil {
+				e.Log(HIGH, "Error rebinding environment variables, keeping the previous values: %v", err)
+				continue
+			}
+			if len(changes) > 0 && onChange != nil {
+				onChange(changes)
+			}
+		}
+	}
+}
+
+// rebind parses the env files into a new map and binds a fresh copy with it,
+// the previous map is restored when parsing or binding fails
+func (e *EnvManager) rebind(watched WatchTarget) ([]Change, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	oldEnvMap, oldOrigins, oldOverrides := e.envMap, e.origins, e.overrides
+	oldBindMode := e.bindMode
+	if e.bindMode == BIND_OS_ONLY {
+		e.bindMode = BIND_FILE_WINS
+	}
+	defer func() { e.bindMode = oldBindMode }()
+
+	if err := e.parseEnv(); err != nil {
+		return nil, err
+	}
+	changes := diffEnvMaps(oldEnvMap, e.envMap)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	fresh := watched.newValue()
+	if err := e.bindEnvWithPrefix(fresh, "", structName(fresh)); err != nil {
+		// the parsed state is only kept when the struct could be bound
+		e.envMap, e.origins, e.overrides = oldEnvMap, oldOrigins, oldOverrides
+		return nil, err
+	}
+	watched.store(fresh)
+	return changes, nil
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func (f fileState) equal(other fileState) bool {
+	return f.modTime.Equal(other.modTime) && f.size == other.size
+}
+
+// returns the modification time and size of every env file, missing files get the zero state
+func (e *EnvManager) fileStates() []fileState {
+	modTimes := make([]fileState, len(e.files))
+	for i, file := range e.files {
+		if info, err := statFile(e.fsys, file); err == nil {
+			modTimes[i] = fileState{info.ModTime(), info.Size()}
+		} else {
+			e.Log(HIGH, "Error reading env file %s: %v", file, err)
+		}
+	}
+	return modTimes
+}
+
+// returns the changes from old to new sorted by key
+func diffEnvMaps(old, new map[string]string) []Change {
+	changes := []Change{}
+	for key, newValue := range new {
+		if oldValue, ok := old[key]; !ok {
+			changes = append(changes, Change{Key: key, Kind: CHANGE_ADDED, NewValue: newValue})
+		} else if oldValue != newValue {
+			changes = append(changes, Change{Key: key, Kind: CHANGE_UPDATED, OldValue: oldValue, NewValue: newValue})
+		}
+	}
+	for key, oldValue := range old {
+		if _, ok := new[key]; !ok {
+			changes = append(changes, Change{Key: key, Kind: CHANGE_REMOVED, OldValue: oldValue})
+		}
+	}
+	slices.SortFunc(changes, func(a, b Change) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return changes
+}
@@ -0,0 +1,129 @@
+package env_manager
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+type TestWatchStruct struct {
+	APIKey  string `env:"GO_ENV_MANAGER_API_KEY"`
+	Workers int    `env:"GO_ENV_MANAGER_WORKERS"`
+}
+
+func TestWatchRebindsOnChange(t *testing.T) {
+	file := newTestEnvFile(t, "GO_ENV_MANAGER_API_KEY=old-key\nGO_ENV_MANAGER_WORKERS=2")
+	envManager := newTestManager(t, file).SetMode(SILENT).SetBindMode(BIND_FILE_WINS).SetWatchInterval(5 * time.Millisecond)
+	watched, err := NewWatched[TestWatchStruct](envManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	initial := watched.Load()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changesCh := make(chan []Change, 1)
+	go envManager.Watch(ctx, watched, func(changes []Change) {
+		changesCh <- changes
+	})
+
+	// readers must be able to read the config while it is reloaded, run with -race
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for ctx.Err() == nil {
+			if config := watched.Load(); config.APIKey == "" {
+				t.Error("Readers must always see a bound config")
+				return
+			}
+		}
+	}()
+	defer readers.Wait()
+	defer cancel()
+
+	// an invalid edit must be rejected and keep the previous values
+	writeWatchedFile(t, file, "GO_ENV_MANAGER_API_KEY=broken\nGO_ENV_MANAGER_WORKERS=many")
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case changes := <-changesCh:
+		t.Fatalf("Invalid edit must not be reported, got %v", changes)
+	default:
+	}
+	assertCondition(t, watched.Load() == initial, "Invalid edits must keep the previous copy")
+
+	writeWatchedFile(t, file, "GO_ENV_MANAGER_API_KEY=new-key\nGO_ENV_MANAGER_WORKERS=2")
+	select {
+	case changes := <-changesCh:
+		assertEqual(t, len(changes), 1, "Only the rotated key must be reported")
+		assertEqual(t, changes[0], Change{Key: "GO_ENV_MANAGER_API_KEY", Kind: CHANGE_UPDATED, OldValue: "old-key", NewValue: "new-key"}, "Invalid change")
+		assertEqual(t, watched.Load().APIKey, "new-key", "APIKey must be rebound")
+		assertEqual(t, watched.Load().Workers, 2, "Workers must be rebound")
+		assertEqual(t, initial.APIKey, "old-key", "Published copies must never be modified")
+	case <-time.After(2 * time.Second):
+		t.Fatal("Watch did not report the change")
+	}
+}
+
+func TestWatchBaselineInOSOnlyMode(t *testing.T) {
+	t.Setenv("GO_ENV_MANAGER_API_KEY", "key")
+	t.Setenv("GO_ENV_MANAGER_WORKERS", "2")
+	file := newTestEnvFile(t, "GO_ENV_MANAGER_API_KEY=key\nGO_ENV_MANAGER_WORKERS=2")
+	envManager := newTestManager(t, file).SetMode(SILENT).SetWatchInterval(5 * time.Millisecond)
+	watched, err := NewWatched[TestWatchStruct](envManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changesCh := make(chan []Change, 1)
+	go envManager.Watch(ctx, watched, func(changes []Change) {
+		changesCh <- changes
+	})
+	// let Watch parse the baseline before the edit
+	time.Sleep(20 * time.Millisecond)
+
+	writeWatchedFile(t, file, "GO_ENV_MANAGER_API_KEY=key\nGO_ENV_MANAGER_WORKERS=4")
+	select {
+	case changes := <-changesCh:
+		assertEqual(t, len(changes), 1, "Only the edited key must be reported")
+		assertEqual(t, changes[0].Key, "GO_ENV_MANAGER_WORKERS", "Invalid changed key")
+		assertEqual(t, watched.Load().Workers, 4, "Workers must be rebound")
+	case <-time.After(2 * time.Second):
+		t.Fatal("Watch did not report the change")
+	}
+}
+
+func TestWatchRebindRollback(t *testing.T) {
+	file := newTestEnvFile(t, "GO_ENV_MANAGER_API_KEY=old-key\nGO_ENV_MANAGER_API_KEY=key\nGO_ENV_MANAGER_WORKERS=2")
+	envManager := newTestManager(t, file).SetMode(SILENT).SetBindMode(BIND_FILE_WINS)
+	watched, err := NewWatched[TestWatchStruct](envManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeWatchedFile(t, file, "GO_ENV_MANAGER_WORKERS=many\nGO_ENV_MANAGER_API_KEY=new-key\nGO_ENV_MANAGER_API_KEY=newer-key")
+	if _, err := envManager.rebind(watched); err == nil {
+		t.Fatal("Rebinding an invalid file must fail")
+	}
+	assertEqual(t, envManager.envMap["GO_ENV_MANAGER_API_KEY"], "key", "The values must be restored")
+	origin, _ := envManager.GetOrigin("GO_ENV_MANAGER_API_KEY")
+	assertEqual(t, origin.Line, 2, "The origins must be restored")
+	overrides := envManager.GetOverrides()
+	assertEqual(t, len(overrides), 1, "The overrides must be restored")
+	assertEqual(t, overrides[0].New.Line, 2, "The overrides must be restored")
+}
+
+func writeWatchedFile(t *testing.T, file, content string) {
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// make sure the modification is visible even on file systems with coarse timestamps
+	modTime := time.Now().Add(time.Duration(len(content)) * time.Second)
+	if err := os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}